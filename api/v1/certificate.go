@@ -5,8 +5,92 @@ import (
 )
 
 const (
-	StateValid   State = "Valid"
-	StateExpired State = "Expired"
+	StateValid    State = "Valid"
+	StateExpired  State = "Expired"
+	StateConflict State = "Conflict"
+)
+
+const (
+	// SecretConflictPolicyFail leaves a conflicting Secret untouched,
+	// and reports the conflict in the status.
+	SecretConflictPolicyFail SecretConflictPolicy = "Fail"
+
+	// SecretConflictPolicyOverwrite deletes a conflicting Secret,
+	// and creates a new one in its place.
+	SecretConflictPolicyOverwrite SecretConflictPolicy = "Overwrite"
+
+	// SecretConflictPolicyAdopt takes ownership of a conflicting Secret,
+	// keeps its metadata and replaces its credentials.
+	SecretConflictPolicyAdopt SecretConflictPolicy = "Adopt"
+)
+
+const (
+	// RotationStrategyInPlace keeps a single mutable Secret,
+	// and swaps its credentials atomically on rotation.
+	RotationStrategyInPlace RotationStrategy = "InPlace"
+
+	// RotationStrategyImmutable writes every revision to an immutable Secret
+	// named <secretRef.name>-<revision>, and keeps the Secret named
+	// <secretRef.name> as a stable alias of the current revision.
+	RotationStrategyImmutable RotationStrategy = "Immutable"
+)
+
+const (
+	// ChainLeaf stores only the leaf certificate in tls.crt.
+	ChainLeaf Chain = "Leaf"
+
+	// ChainFull stores the leaf certificate followed by the certificates
+	// of the issuing CA in tls.crt.
+	ChainFull Chain = "Full"
+)
+
+const (
+	// DeletionPolicyDelete leaves the Secret to the garbage collector.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+
+	// DeletionPolicyRetain orphans the Secret, so that it outlives the Certificate.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+
+	// DeletionPolicyRevokeAndDelete revokes the certificate, and deletes the Secret.
+	DeletionPolicyRevokeAndDelete DeletionPolicy = "RevokeAndDelete"
+)
+
+const (
+	// OutputFormatPKCS12 writes keystore.p12 and truststore.p12.
+	OutputFormatPKCS12 OutputFormatType = "PKCS12"
+
+	// OutputFormatJKS writes keystore.jks and truststore.jks.
+	OutputFormatJKS OutputFormatType = "JKS"
+
+	// OutputFormatCombinedPEM writes the private key followed by
+	// the certificate chain to tls-combined.pem.
+	OutputFormatCombinedPEM OutputFormatType = "CombinedPEM"
+
+	// OutputFormatEncryptedPKCS8 writes the PKCS#8 private key, encrypted
+	// with PBES2 and AES-256, to tls-encrypted.key.
+	OutputFormatEncryptedPKCS8 OutputFormatType = "EncryptedPKCS8"
+
+	// OutputFormatDER writes the DER encoded certificate to tls.der,
+	// and the DER encoded PKCS#8 private key to key.der.
+	OutputFormatDER OutputFormatType = "DER"
+)
+
+const (
+	// UsageServerAuth allows the certificate to authenticate a server.
+	UsageServerAuth Usage = "ServerAuth"
+
+	// UsageClientAuth allows the certificate to authenticate a client.
+	UsageClientAuth Usage = "ClientAuth"
+)
+
+const (
+	// ConditionSecretConflict is set while the referenced Secret exists,
+	// but can neither be adopted nor replaced.
+	ConditionSecretConflict = "SecretConflict"
+
+	// ConditionExpiringSoon is set once the certificate held by the Secret
+	// has entered the last third of its lifetime, until it is renewed.
+	ConditionExpiringSoon = "ExpiringSoon"
 )
 
 // CertificateSpec defines the desired state of the Certificate.
@@ -25,21 +109,198 @@ type CertificateSpec struct {
 	// Subject alternate names, other than DNSName.
 	AltNames []string `json:"altNames,omitempty"`
 
+	// IP addresses the certificate is valid for, next to the loopback
+	// address every certificate holds.
+	// +optional
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+
+	// URIs the certificate is valid for, such as SPIFFE IDs.
+	// +optional
+	URIs []string `json:"uris,omitempty"`
+
+	// Extended key usages of the certificate. The certificate is valid
+	// for any usage if empty.
+	// +listType=set
+	// +optional
+	Usages []Usage `json:"usages,omitempty"`
+
 	// A reference to the Secret object in which the certificate is stored.
 	SecretRef SecretRef `json:"secretRef"`
+
+	// What to do when the referenced Secret already exists, but does not hold
+	// credentials matching this Certificate. A matching Secret is always adopted.
+	// +kubebuilder:validation:Enum=Fail;Overwrite;Adopt
+	// +kubebuilder:default=Fail
+	SecretConflictPolicy SecretConflictPolicy `json:"secretConflictPolicy,omitempty"`
+
+	// How the credentials in the Secret are rotated when a new certificate is issued.
+	// +kubebuilder:validation:Enum=InPlace;Immutable
+	// +kubebuilder:default=InPlace
+	RotationStrategy RotationStrategy `json:"rotationStrategy,omitempty"`
+
+	// The certificates stored in tls.crt. The certificate of the issuing CA
+	// is always stored in ca.crt as well.
+	// +kubebuilder:validation:Enum=Leaf;Full
+	// +kubebuilder:default=Leaf
+	Chain Chain `json:"chain,omitempty"`
+
+	// What happens to the Secret when the Certificate is deleted.
+	// +kubebuilder:validation:Enum=Delete;Retain;RevokeAndDelete
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Names of the keys the credentials are stored under in the Secret.
+	// The Secret is of type Opaque unless the private key and certificate
+	// are stored under tls.key and tls.crt.
+	// +optional
+	SecretKeys *SecretKeys `json:"secretKeys,omitempty"`
+
+	// Additional formats of the credentials, written to the same Secret.
+	// They are regenerated whenever the credentials change.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	AdditionalOutputFormats []OutputFormat `json:"additionalOutputFormats,omitempty"`
+
+	// Copies of the Secret kept in other namespaces.
+	// +optional
+	SecretReplication *SecretReplication `json:"secretReplication,omitempty"`
+
+	// Labels and annotations of the Secret. They are reconciled on every pass;
+	// keys removed from the template are removed from the Secret as well.
+	// +optional
+	SecretTemplate *SecretTemplate `json:"secretTemplate,omitempty"`
 }
 
+// SecretTemplate defines the metadata of the generated Secret.
+type SecretTemplate struct {
+	// Labels to set on the Secret.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations to set on the Secret.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SecretReplication defines the namespaces the Secret is copied to.
+type SecretReplication struct {
+	// Selects the namespaces to copy the Secret to. Only namespaces labeled
+	// certs.k8c.io/allow-replication=true are ever selected.
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+}
+
+// SecretKeys defines the names of the keys in the Secret data.
+// +kubebuilder:validation:XValidation:rule="[self.privateKey, self.certificate, self.ca].all(k, [self.privateKey, self.certificate, self.ca].exists_one(o, o == k)) && (!has(self.chain) || !(self.chain in [self.privateKey, self.certificate, self.ca]))",message="secret keys must be distinct"
+type SecretKeys struct {
+	// Key of the PEM encoded private key.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +kubebuilder:default=tls.key
+	PrivateKey string `json:"privateKey,omitempty"`
+
+	// Key of the PEM encoded certificate, in the shape set by chain.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +kubebuilder:default=tls.crt
+	Certificate string `json:"certificate,omitempty"`
+
+	// Key of the PEM encoded certificate of the issuing CA.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +kubebuilder:default=ca.crt
+	CA string `json:"ca,omitempty"`
+
+	// Key of the full PEM encoded chain, the certificate followed by the
+	// issuing CA, regardless of chain. Not written if empty.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +optional
+	Chain string `json:"chain,omitempty"`
+}
+
+type SecretConflictPolicy string
+
+type RotationStrategy string
+
+type DeletionPolicy string
+
+type Chain string
+
+// Usage is an extended key usage of a certificate.
+// +kubebuilder:validation:Enum=ServerAuth;ClientAuth
+type Usage string
+
 type SecretRef struct {
 	Name string `json:"name"`
 }
 
+// SecretKeyRef references a key of a Secret in the namespace of the Certificate.
+type SecretKeyRef struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+
+	// Key in the data of the Secret.
+	// +kubebuilder:default=password
+	Key string `json:"key,omitempty"`
+}
+
+// OutputFormat defines an additional format of the credentials.
+// +kubebuilder:validation:XValidation:rule="!(self.type in ['PKCS12', 'JKS', 'EncryptedPKCS8']) || has(self.passwordSecretRef)",message="passwordSecretRef is required for keystores and encrypted keys"
+type OutputFormat struct {
+	// Type of the output format.
+	// +kubebuilder:validation:Enum=PKCS12;JKS;CombinedPEM;DER;EncryptedPKCS8
+	Type OutputFormatType `json:"type"`
+
+	// A reference to the password protecting the keystore and truststore,
+	// or the encrypted private key. Required for PKCS12, JKS and EncryptedPKCS8.
+	// The Secret must be labelled certs.k8c.io/managed=password.
+	// +optional
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
+}
+
+type OutputFormatType string
+
 type State string
 
 // CertificateStatus defines the observed state of the certificate.
 type CertificateStatus struct {
 	// State of the Certificate.
-	// +kubebuilder:validation:Enum=Valid;Expired
+	// +kubebuilder:validation:Enum=Valid;Expired;Conflict
 	State State `json:"state"`
+
+	// Revision of the credentials currently held by the Secret.
+	// It is incremented on every issuance.
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+
+	// Name of the Secret the credentials were last written to. Once
+	// spec.secretRef.name changes, the previous Secret is deleted or
+	// retained, as set by the deletion policy.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Namespaces holding a copy of the Secret.
+	// +listType=set
+	// +optional
+	ReplicaNamespaces []string `json:"replicaNamespaces,omitempty"`
+
+	// The Gateway listeners served by the Certificate, for Certificates
+	// created for a Gateway.
+	// +optional
+	GatewayListeners []GatewayListenerReference `json:"gatewayListeners,omitempty"`
+
+	// Conditions describe the current state of the Certificate in detail.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// GatewayListenerReference names a listener of a Gateway in the
+// namespace of the Certificate.
+type GatewayListenerReference struct {
+	// Name of the Gateway.
+	Gateway string `json:"gateway"`
+
+	// Name of the listener.
+	Listener string `json:"listener"`
 }
 
 //+kubebuilder:object:root=true
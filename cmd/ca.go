@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"certificate-manager/internal/cert"
+)
+
+// caCRLKey is the key of the revocation list in the Secret of the CA.
+const caCRLKey = "ca.crl"
+
+// caSecretTimeout bounds reading and writing the Secret of the CA.
+const caSecretTimeout = time.Minute
+
+// newCA returns the CA persisted in the Secret set with --ca-secret, or a new
+// one if it is not set.
+func newCA(cfg *rest.Config, opts *options) (cert.CertAuthority, error) {
+	var caOpts []cert.Option
+	if opts.CRLURL != "" {
+		caOpts = append(caOpts, cert.WithCRLDistributionPoint(opts.CRLURL))
+	}
+
+	key, persisted, err := opts.caSecret()
+	if err != nil {
+		return nil, err
+	}
+	if !persisted {
+		return cert.Authority(caOpts...)
+	}
+
+	// the cache of the manager is not started yet, so read the Secret directly
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), caSecretTimeout)
+	defer cancel()
+
+	return loadCA(ctx, c, key, caOpts...)
+}
+
+// loadCA returns the CA persisted in the Secret, so that every replica of the
+// manager, and every restart, signs with the same CA. The Secret is created
+// with a new CA if it does not exist, and the CA is replaced once it expired.
+// Replicas starting at the same time agree on the CA created first. The
+// revocation list is persisted in the Secret as well, see persistedCA.
+func loadCA(ctx context.Context, c client.Client, key types.NamespacedName, opts ...cert.Option) (cert.CertAuthority, error) {
+	var sec corev1.Secret
+	err := c.Get(ctx, key, &sec)
+	if apierrors.IsNotFound(err) {
+		sec = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Type:       corev1.SecretTypeTLS,
+		}
+		if sec.Data, err = newCAData(); err != nil {
+			return nil, err
+		}
+
+		if err = c.Create(ctx, &sec); apierrors.IsAlreadyExists(err) {
+			err = c.Get(ctx, key, &sec)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get CA secret %s", key)
+	}
+
+	if crt, err := cert.Decode(sec.Data[corev1.TLSCertKey]); err == nil && time.Now().After(crt.NotAfter) {
+		if sec.Data, err = newCAData(); err != nil {
+			return nil, err
+		}
+
+		// a conflict means another replica replaced it first
+		if err := c.Update(ctx, &sec); err != nil {
+			return nil, errors.Wrapf(err, "unable to replace expired CA in secret %s", key)
+		}
+	}
+
+	ca, err := cert.LoadAuthority(sec.Data[corev1.TLSPrivateKeyKey], sec.Data[corev1.TLSCertKey], opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid CA in secret %s", key)
+	}
+
+	if crl, ok := sec.Data[caCRLKey]; ok {
+		if err := ca.LoadRevocations(crl); err != nil {
+			return nil, errors.Wrapf(err, "invalid revocation list in secret %s", key)
+		}
+	}
+
+	return &persistedCA{CertAuthority: ca, client: c, key: key}, nil
+}
+
+// persistedCA keeps the revocation list of the CA in the Secret the CA is
+// persisted in, so that revoked certificates stay revoked after a restart,
+// and every replica serves all revocations, not only its own.
+type persistedCA struct {
+	cert.CertAuthority
+
+	client client.Client
+	key    types.NamespacedName
+}
+
+// Revoke revokes the certificate, and writes the revocation list, including
+// the revocations read from the Secret, back to it.
+func (ca *persistedCA) Revoke(crt []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), caSecretTimeout)
+	defer cancel()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sec, err := ca.refresh(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := ca.CertAuthority.Revoke(crt); err != nil {
+			return err
+		}
+
+		crl, err := ca.CertAuthority.CRL()
+		if err != nil {
+			return err
+		}
+
+		sec.Data[caCRLKey] = crl
+		err = ca.client.Update(ctx, sec)
+
+		return errors.Wrapf(err, "unable to persist revocation list in secret %s", ca.key)
+	})
+}
+
+// CRL returns the revocation list, including the revocations read from the
+// Secret.
+func (ca *persistedCA) CRL() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), caSecretTimeout)
+	defer cancel()
+
+	if _, err := ca.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return ca.CertAuthority.CRL()
+}
+
+// refresh adds the revocations persisted in the Secret to those of the CA,
+// and returns the Secret.
+func (ca *persistedCA) refresh(ctx context.Context) (*corev1.Secret, error) {
+	var sec corev1.Secret
+	if err := ca.client.Get(ctx, ca.key, &sec); err != nil {
+		return nil, errors.Wrapf(err, "unable to get CA secret %s", ca.key)
+	}
+
+	if crl, ok := sec.Data[caCRLKey]; ok {
+		if err := ca.LoadRevocations(crl); err != nil {
+			return nil, errors.Wrapf(err, "invalid revocation list in secret %s", ca.key)
+		}
+	}
+	if sec.Data == nil {
+		sec.Data = map[string][]byte{}
+	}
+
+	return &sec, nil
+}
+
+// newCAData returns the Secret data holding the credentials of a new CA.
+func newCAData() (map[string][]byte, error) {
+	key, crt, err := cert.NewAuthorityCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		corev1.TLSPrivateKeyKey: key,
+		corev1.TLSCertKey:       crt,
+	}, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"certificate-manager/internal/cert"
+)
+
+var caSecretKey = types.NamespacedName{Namespace: "certs", Name: "certificate-manager-ca"}
+
+func TestLoadCA(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	created, err := loadCA(ctx, c, caSecretKey)
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	var sec corev1.Secret
+	if err := c.Get(ctx, caSecretKey, &sec); err != nil {
+		t.Fatalf("expected the CA to be persisted: %v", err)
+	}
+	if !bytes.Equal(sec.Data[corev1.TLSCertKey], created.CACert()) {
+		t.Fatal("persisted CA certificate does not match the created one")
+	}
+
+	// a restart, or another replica, signs with the same CA
+	loaded, err := loadCA(ctx, c, caSecretKey)
+	if err != nil {
+		t.Fatalf("unable to load CA: %v", err)
+	}
+	if !bytes.Equal(loaded.CACert(), created.CACert()) {
+		t.Fatal("expected the persisted CA to be loaded")
+	}
+}
+
+func TestLoadCAConcurrentStart(t *testing.T) {
+	ctx := context.Background()
+
+	// another replica creates the Secret first
+	var winner []byte
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			data, err := newCAData()
+			if err != nil {
+				return err
+			}
+			winner = data[corev1.TLSCertKey]
+
+			sec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: caSecretKey.Name, Namespace: caSecretKey.Namespace},
+				Data:       data,
+			}
+			if err := c.Create(ctx, sec); err != nil {
+				return err
+			}
+
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+
+	ca, err := loadCA(ctx, c, caSecretKey)
+	if err != nil {
+		t.Fatalf("unable to load CA: %v", err)
+	}
+	if !bytes.Equal(ca.CACert(), winner) {
+		t.Fatal("expected the CA created first to be loaded")
+	}
+}
+
+func TestLoadCAReplacesExpired(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"certificate-manager"}},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(-24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create CA certificate: %v", err)
+	}
+	expired := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: caSecretKey.Name, Namespace: caSecretKey.Namespace},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+			corev1.TLSCertKey:       expired,
+		},
+	}).Build()
+
+	ca, err := loadCA(ctx, c, caSecretKey)
+	if err != nil {
+		t.Fatalf("unable to load CA: %v", err)
+	}
+	if bytes.Equal(ca.CACert(), expired) {
+		t.Fatal("expected the expired CA to be replaced")
+	}
+
+	var sec corev1.Secret
+	if err := c.Get(ctx, caSecretKey, &sec); err != nil {
+		t.Fatalf("unable to get CA secret: %v", err)
+	}
+	if !bytes.Equal(sec.Data[corev1.TLSCertKey], ca.CACert()) {
+		t.Fatal("expected the new CA to be persisted")
+	}
+}
+
+// revokedSerials returns the serial numbers in the PEM encoded revocation list.
+func revokedSerials(t *testing.T, data []byte) []*big.Int {
+	t.Helper()
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no PEM encoded revocation list")
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("unable to parse revocation list: %v", err)
+	}
+
+	serials := make([]*big.Int, 0, len(crl.RevokedCertificateEntries))
+	for _, entry := range crl.RevokedCertificateEntries {
+		serials = append(serials, entry.SerialNumber)
+	}
+
+	return serials
+}
+
+func TestPersistedCARevocations(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	ca, err := loadCA(ctx, c, caSecretKey, cert.WithCRLDistributionPoint("http://crl.certs.svc:8080"+crlPath))
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+	// another replica, started before the revocation
+	replica, err := loadCA(ctx, c, caSecretKey)
+	if err != nil {
+		t.Fatalf("unable to load CA: %v", err)
+	}
+
+	_, crt, err := ca.IssueCert(cert.Request{Organization: "k8c", DNSName: "todo.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+	issued, _ := cert.Decode(crt)
+	if len(issued.CRLDistributionPoints) != 1 || issued.CRLDistributionPoints[0] != "http://crl.certs.svc:8080/ca.crl" {
+		t.Fatalf("expected the CRL distribution point to be set, got %v", issued.CRLDistributionPoints)
+	}
+
+	if err := ca.Revoke(crt); err != nil {
+		t.Fatalf("unable to revoke certificate: %v", err)
+	}
+
+	var sec corev1.Secret
+	if err := c.Get(ctx, caSecretKey, &sec); err != nil {
+		t.Fatalf("unable to get CA secret: %v", err)
+	}
+	if serials := revokedSerials(t, sec.Data[caCRLKey]); len(serials) != 1 || serials[0].Cmp(issued.SerialNumber) != 0 {
+		t.Fatalf("expected serial %x to be persisted, got %v", issued.SerialNumber, serials)
+	}
+
+	// a restart, and the other replica, serve the revocation as well
+	restarted, err := loadCA(ctx, c, caSecretKey)
+	if err != nil {
+		t.Fatalf("unable to load CA: %v", err)
+	}
+	for name, ca := range map[string]cert.CertAuthority{"restarted": restarted, "replica": replica} {
+		crl, err := ca.CRL()
+		if err != nil {
+			t.Fatalf("unable to create revocation list of the %s CA: %v", name, err)
+		}
+		if serials := revokedSerials(t, crl); len(serials) != 1 || serials[0].Cmp(issued.SerialNumber) != 0 {
+			t.Fatalf("expected the %s CA to list serial %x, got %v", name, issued.SerialNumber, serials)
+		}
+	}
+
+	// certificates of another CA are not revoked, and nothing is written
+	other, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+	_, foreign, err := other.IssueCert(cert.Request{Organization: "k8c", DNSName: "todo.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+	if err := restarted.Revoke(foreign); !errors.Is(err, cert.ErrUnknownIssuer) {
+		t.Fatalf("expected an unknown issuer, got %v", err)
+	}
+
+	var after corev1.Secret
+	if err := c.Get(ctx, caSecretKey, &after); err != nil {
+		t.Fatalf("unable to get CA secret: %v", err)
+	}
+	if after.ResourceVersion != sec.ResourceVersion {
+		t.Fatal("expected the CA secret not to be updated")
+	}
+}
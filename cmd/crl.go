@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+
+	"certificate-manager/internal/cert"
+)
+
+// crlPath is the path of the revocation list on the metrics endpoint.
+const crlPath = "/ca.crl"
+
+// crlHandler serves the PEM encoded revocation list of the CA.
+func crlHandler(ca cert.CertAuthority) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		crl, err := ca.CRL()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		_, _ = w.Write(crl)
+	})
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"flag"
+	"net/http"
 	"os"
 
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	certsv1 "certificate-manager/api/v1"
 	"certificate-manager/internal/cert"
 	"certificate-manager/internal/controller"
+	"certificate-manager/internal/metrics"
 )
 
 var (
@@ -24,34 +35,252 @@ func init() {
 	utilruntime.Must(certsv1.AddToScheme(scheme))
 }
 
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+
 func main() {
-	ctrl.SetLogger(zap.New())
+	opts, err := parseOptions(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		ctrl.SetLogger(zap.New())
+		setupLog.Error(err, "unable to parse options")
+		os.Exit(1)
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-	})
+	zapOpts, err := opts.zapOptions()
 	if err != nil {
-		setupLog.Error(err, "unable to start manager")
+		ctrl.SetLogger(zap.New())
+		setupLog.Error(err, "unable to configure logger")
 		os.Exit(1)
 	}
+	ctrl.SetLogger(zap.New(zapOpts...))
 
-	ca, err := cert.Authority()
+	trustBundleSelector, err := opts.trustBundleSelector()
+	if err != nil {
+		setupLog.Error(err, "unable to configure trust bundle")
+		os.Exit(1)
+	}
+
+	trustBundlePasswordSecret, err := opts.trustBundlePasswordSecret()
+	if err != nil {
+		setupLog.Error(err, "unable to configure trust bundle")
+		os.Exit(1)
+	}
+
+	cacheOpts := cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Secret{}:    controller.SecretCacheOptions(),
+			&corev1.ConfigMap{}: controller.TrustBundleCacheOptions(),
+		},
+	}
+	if len(opts.Namespaces) > 0 {
+		cacheOpts.DefaultNamespaces = make(map[string]cache.Config, len(opts.Namespaces))
+		for _, ns := range opts.Namespaces {
+			cacheOpts.DefaultNamespaces[ns] = cache.Config{}
+		}
+	}
+
+	cfg := ctrl.GetConfigOrDie()
+
+	ca, err := newCA(cfg, opts)
 	if err != nil {
 		setupLog.Error(err, "unable to initialize certificate authority")
+		os.Exit(1)
+	}
+
+	if caCert, err := cert.Decode(ca.CACert()); err == nil {
+		metrics.RecordCA(caCert)
+	}
+
+	// the servers need the serving certificate before they start
+	var serving *servingCert
+	if len(opts.ServingCertDNSNames) > 0 {
+		serving = &servingCert{ca: ca, dir: opts.ServingCertDir, dnsNames: opts.ServingCertDNSNames}
+		if err := serving.write(); err != nil {
+			setupLog.Error(err, "unable to write serving certificate")
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme: scheme,
+		Cache:  cacheOpts,
+		Metrics: metricsserver.Options{
+			BindAddress:   opts.MetricsBindAddress,
+			SecureServing: opts.MetricsSecure,
+			CertDir:       opts.ServingCertDir,
+			ExtraHandlers: map[string]http.Handler{
+				crlPath: crlHandler(ca),
+			},
+		},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			CertDir: opts.ServingCertDir,
+		}),
+		HealthProbeBindAddress:        opts.HealthProbeBindAddress,
+		LeaderElection:                opts.LeaderElect,
+		LeaderElectionID:              opts.LeaderElectionID,
+		LeaderElectionNamespace:       opts.LeaderElectionNamespace,
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &opts.GracefulShutdownTimeout.Duration,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
 	}
 
 	if err = (&controller.CertificateReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		CA:     ca,
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("certificate-controller"),
+		CA:        ca,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Certificate")
 		os.Exit(1)
 	}
 
+	if trustBundleSelector != nil {
+		if err = (&controller.TrustBundleReconciler{
+			Client:         mgr.GetClient(),
+			APIReader:      mgr.GetAPIReader(),
+			Scheme:         mgr.GetScheme(),
+			Recorder:       mgr.GetEventRecorderFor("trust-bundle-controller"),
+			CA:             ca,
+			Selector:       trustBundleSelector,
+			Formats:        opts.TrustBundleFormats,
+			PasswordSecret: trustBundlePasswordSecret,
+			Overlap:        opts.TrustBundleOverlap.Duration,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "TrustBundle")
+			os.Exit(1)
+		}
+	}
+
+	if opts.IngressShim {
+		if err = (&controller.IngressReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("ingress-shim"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+			os.Exit(1)
+		}
+	}
+
+	if opts.GatewayShim {
+		installed, err := controller.GatewayAPIInstalled(mgr.GetRESTMapper())
+		if err != nil {
+			setupLog.Error(err, "unable to discover the Gateway API")
+			os.Exit(1)
+		}
+
+		if !installed {
+			setupLog.Info("Gateway API not installed, not watching Gateways")
+		} else if err = (&controller.GatewayReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("gateway-shim"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+			os.Exit(1)
+		}
+	}
+
+	if opts.ServiceShim || opts.StatefulSetShim {
+		// outside of a Pod, e.g. with make run, the cluster domain must be set
+		clusterDomain, err := opts.clusterDomain("/etc/resolv.conf")
+		if err != nil {
+			setupLog.Error(err, "unable to determine the cluster domain")
+			os.Exit(1)
+		}
+
+		if opts.ServiceShim {
+			if err = (&controller.ServiceReconciler{
+				Client:        mgr.GetClient(),
+				Scheme:        mgr.GetScheme(),
+				Recorder:      mgr.GetEventRecorderFor("service-shim"),
+				ClusterDomain: clusterDomain,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "Service")
+				os.Exit(1)
+			}
+		}
+
+		if opts.StatefulSetShim {
+			if err = (&controller.StatefulSetReconciler{
+				Client:        mgr.GetClient(),
+				Scheme:        mgr.GetScheme(),
+				Recorder:      mgr.GetEventRecorderFor("statefulset-shim"),
+				ClusterDomain: clusterDomain,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "StatefulSet")
+				os.Exit(1)
+			}
+		}
+	}
+
+	if opts.PodInjector {
+		(&controller.PodInjector{
+			Client:    mgr.GetClient(),
+			Decoder:   admission.NewDecoder(mgr.GetScheme()),
+			MountPath: opts.InjectMountPath,
+		}).SetupWebhookWithManager(mgr)
+	}
+
+	if opts.RolloutRestart {
+		limiter := rate.NewLimiter(rate.Inf, 1)
+		if opts.RolloutInterval.Duration > 0 {
+			limiter = rate.NewLimiter(rate.Every(opts.RolloutInterval.Duration), 1)
+		}
+
+		for _, kind := range controller.WorkloadKinds {
+			if err = (&controller.RolloutReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("rollout-controller"),
+				Kind:     kind,
+				Limiter:  limiter,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "Rollout", "kind", kind.Kind)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if serving != nil {
+		if err := mgr.Add(serving); err != nil {
+			setupLog.Error(err, "unable to set up serving certificate renewal")
+			os.Exit(1)
+		}
+	}
+
+	if opts.CAInjector {
+		for _, kind := range controller.InjectableKinds {
+			if err = (&controller.CAInjectorReconciler{
+				Client: mgr.GetClient(),
+				CA:     ca,
+				Kind:   kind,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "CAInjector", "kind", kind.Kind)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+
+	if err := mgr.AddReadyzCheck("ca", func(_ *http.Request) error {
+		return ca.Ping()
+	}); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err = mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	setupLog.Info("manager stopped")
 }
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+
+	"certificate-manager/internal/controller"
+)
+
+const (
+	logFormatJSON    = "json"
+	logFormatConsole = "console"
+)
+
+// options configures the controller manager. Every option can be set
+// in the config file passed with --config, and overridden with a flag.
+type options struct {
+	// MetricsBindAddress is the address the metrics endpoint binds to.
+	MetricsBindAddress string `json:"metricsBindAddress,omitempty"`
+
+	// HealthProbeBindAddress is the address the health probe endpoint binds to.
+	HealthProbeBindAddress string `json:"healthProbeBindAddress,omitempty"`
+
+	// LeaderElect enables leader election, so that only one replica reconciles.
+	LeaderElect bool `json:"leaderElect,omitempty"`
+
+	// LeaderElectionID is the name of the Lease used for leader election.
+	LeaderElectionID string `json:"leaderElectionID,omitempty"`
+
+	// LeaderElectionNamespace is the namespace of the leader election Lease.
+	// Defaults to the namespace the manager runs in.
+	LeaderElectionNamespace string `json:"leaderElectionNamespace,omitempty"`
+
+	// CASecret names the Secret, as namespace/name, the CA is persisted in,
+	// so that it survives restarts and is shared by all replicas. The CA is
+	// created anew on every start if empty.
+	CASecret string `json:"caSecret,omitempty"`
+
+	// CRLURL is the URL the revocation list of the CA is served at, set as
+	// the CRL distribution point of issued certificates. None is set if empty.
+	CRLURL string `json:"crlURL,omitempty"`
+
+	// Namespaces restricts the manager to the given namespaces.
+	// All namespaces are watched if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// GracefulShutdownTimeout is the time given to runnables to stop.
+	GracefulShutdownTimeout metav1.Duration `json:"gracefulShutdownTimeout,omitempty"`
+
+	// LogLevel is one of debug, info, warn or error.
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// LogFormat is one of json or console.
+	LogFormat string `json:"logFormat,omitempty"`
+
+	// TrustBundleNamespaceSelector selects the namespaces to publish the
+	// CA trust bundle in. The trust bundle is not published if empty.
+	TrustBundleNamespaceSelector string `json:"trustBundleNamespaceSelector,omitempty"`
+
+	// TrustBundleFormats lists the truststore variants of the trust bundle;
+	// jks and pkcs12.
+	TrustBundleFormats []string `json:"trustBundleFormats,omitempty"`
+
+	// TrustBundlePasswordSecret names the Secret, as namespace/name, holding
+	// the password of the truststore variants under the key password.
+	TrustBundlePasswordSecret string `json:"trustBundlePasswordSecret,omitempty"`
+
+	// TrustBundleOverlap is the time the previous CA is kept in the trust
+	// bundle after the CA changed.
+	TrustBundleOverlap metav1.Duration `json:"trustBundleOverlap,omitempty"`
+
+	// CAInjector enables injecting the CA into webhook configurations,
+	// CRD conversion webhooks and APIServices annotated with a Certificate.
+	// It is off by default, as it watches these cluster-scoped kinds.
+	CAInjector bool `json:"caInjector,omitempty"`
+
+	// IngressShim enables creating Certificates for the TLS Secrets of
+	// Ingresses annotated with certs.k8c.io/issue.
+	IngressShim bool `json:"ingressShim,omitempty"`
+
+	// GatewayShim enables creating Certificates for the Secrets referenced by
+	// Gateways annotated with certs.k8c.io/issue, if the Gateway API is installed.
+	GatewayShim bool `json:"gatewayShim,omitempty"`
+
+	// ServiceShim enables creating Certificates for the in-cluster DNS names
+	// of Services annotated with certs.k8c.io/secret-name.
+	ServiceShim bool `json:"serviceShim,omitempty"`
+
+	// StatefulSetShim enables creating a Certificate per replica of the
+	// StatefulSets annotated with certs.k8c.io/pod-secret-name.
+	StatefulSetShim bool `json:"statefulSetShim,omitempty"`
+
+	// ClusterDomain is the DNS domain of the cluster. It is read from the
+	// search domains of /etc/resolv.conf if empty.
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+
+	// PodInjector enables the webhook mounting the Secret of the Certificate
+	// named by certs.k8c.io/inject into Pods. It needs a serving certificate.
+	PodInjector bool `json:"podInjector,omitempty"`
+
+	// InjectMountPath is where the webhook mounts the Secret, unless set on the Pod.
+	InjectMountPath string `json:"injectMountPath,omitempty"`
+
+	// RolloutRestart enables restarting the Deployments, StatefulSets and
+	// DaemonSets using a managed Secret once it is renewed.
+	RolloutRestart bool `json:"rolloutRestart,omitempty"`
+
+	// RolloutInterval is the minimum time between two restarts, across all
+	// workloads. Restarts are not throttled if zero.
+	RolloutInterval metav1.Duration `json:"rolloutInterval,omitempty"`
+
+	// ServingCertDir is the directory the webhook and metrics servers read
+	// their serving certificate from.
+	ServingCertDir string `json:"servingCertDir,omitempty"`
+
+	// ServingCertDNSNames are the DNS names of the serving certificate the
+	// manager issues itself. It is left to others to provide if empty.
+	ServingCertDNSNames []string `json:"servingCertDNSNames,omitempty"`
+
+	// MetricsSecure serves the metrics endpoint over HTTPS.
+	MetricsSecure bool `json:"metricsSecure,omitempty"`
+}
+
+func defaultOptions() *options {
+	return &options{
+		MetricsBindAddress:      ":8080",
+		HealthProbeBindAddress:  ":8081",
+		LeaderElectionID:        "certificate-manager.certs.k8c.io",
+		GracefulShutdownTimeout: metav1.Duration{Duration: 30 * time.Second},
+		LogLevel:                "info",
+		LogFormat:               logFormatJSON,
+		TrustBundleOverlap:      metav1.Duration{Duration: 24 * time.Hour},
+		IngressShim:             true,
+		GatewayShim:             true,
+		ServiceShim:             true,
+		StatefulSetShim:         true,
+		InjectMountPath:         "/etc/tls",
+		RolloutInterval:         metav1.Duration{Duration: 30 * time.Second},
+		ServingCertDir:          filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs"),
+	}
+}
+
+// parseOptions reads the config file, if any, and applies the command line
+// flags on top of it.
+func parseOptions(fs *flag.FlagSet, args []string) (*options, error) {
+	var configFile string
+
+	opts := defaultOptions()
+	fs.StringVar(&configFile, "config", "",
+		"Path to a YAML file with the manager options. Flags take precedence over the file.")
+	opts.bindFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if configFile == "" {
+		return opts, nil
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading config file")
+	}
+
+	if err := yaml.UnmarshalStrict(data, opts); err != nil {
+		return nil, errors.Wrap(err, "error parsing config file")
+	}
+
+	// parse again, so that explicit flags win over the config file
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+func (o *options) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.MetricsBindAddress, "metrics-bind-address", o.MetricsBindAddress,
+		"The address the metrics endpoint binds to. Use 0 to disable the metrics endpoint.")
+	fs.StringVar(&o.HealthProbeBindAddress, "health-probe-bind-address", o.HealthProbeBindAddress,
+		"The address the health probe endpoint binds to.")
+	fs.BoolVar(&o.LeaderElect, "leader-elect", o.LeaderElect,
+		"Enable leader election, so that only one replica of the manager reconciles at a time.")
+	fs.StringVar(&o.LeaderElectionID, "leader-election-id", o.LeaderElectionID,
+		"The name of the Lease used for leader election.")
+	fs.StringVar(&o.LeaderElectionNamespace, "leader-election-namespace", o.LeaderElectionNamespace,
+		"The namespace of the leader election Lease. Defaults to the namespace of the manager.")
+	fs.StringVar(&o.CASecret, "ca-secret", o.CASecret,
+		"The namespace/name of the Secret the CA is persisted in, and shared by all replicas through. "+
+			"The CA is created anew on every start if empty.")
+	fs.StringVar(&o.CRLURL, "crl-url", o.CRLURL,
+		"The URL of the revocation list of the CA, served at "+crlPath+" on the metrics endpoint, "+
+			"set as the CRL distribution point of issued certificates. None is set if empty.")
+	fs.Func("namespaces", "Comma separated list of namespaces to watch. All namespaces are watched if empty.",
+		func(v string) error {
+			o.Namespaces = splitList(v)
+			return nil
+		})
+	fs.DurationVar(&o.GracefulShutdownTimeout.Duration, "graceful-shutdown-timeout", o.GracefulShutdownTimeout.Duration,
+		"The time given to the manager to stop its controllers and servers before exiting.")
+	fs.StringVar(&o.LogLevel, "log-level", o.LogLevel, "The log level; one of debug, info, warn or error.")
+	fs.StringVar(&o.LogFormat, "log-format", o.LogFormat, "The log format; one of json or console.")
+	fs.StringVar(&o.TrustBundleNamespaceSelector, "trust-bundle-namespace-selector", o.TrustBundleNamespaceSelector,
+		"Label selector of the namespaces to publish the CA trust bundle in. The trust bundle is not published if empty.")
+	fs.Func("trust-bundle-formats", "Comma separated list of truststore variants of the trust bundle; jks and pkcs12.",
+		func(v string) error {
+			o.TrustBundleFormats = splitList(v)
+			return nil
+		})
+	fs.StringVar(&o.TrustBundlePasswordSecret, "trust-bundle-password-secret", o.TrustBundlePasswordSecret,
+		"The namespace/name of the Secret holding the password of the truststore variants of the trust bundle, "+
+			"under the key password. Required for truststore variants.")
+	fs.DurationVar(&o.TrustBundleOverlap.Duration, "trust-bundle-overlap", o.TrustBundleOverlap.Duration,
+		"The time the previous CA is kept in the trust bundle after the CA changed.")
+	fs.BoolVar(&o.CAInjector, "ca-injector", o.CAInjector,
+		"Inject the CA into webhook configurations, CRD conversion webhooks and APIServices annotated with "+
+			"certs.k8c.io/inject-ca-from.")
+	fs.BoolVar(&o.IngressShim, "ingress-shim", o.IngressShim,
+		"Create Certificates for the TLS Secrets of Ingresses annotated with certs.k8c.io/issue.")
+	fs.BoolVar(&o.GatewayShim, "gateway-shim", o.GatewayShim,
+		"Create Certificates for the Secrets referenced by Gateways annotated with certs.k8c.io/issue. "+
+			"Only takes effect if the Gateway API is installed.")
+	fs.BoolVar(&o.ServiceShim, "service-shim", o.ServiceShim,
+		"Create Certificates for the in-cluster DNS names of Services annotated with certs.k8c.io/secret-name.")
+	fs.BoolVar(&o.StatefulSetShim, "statefulset-shim", o.StatefulSetShim,
+		"Create a Certificate per replica of StatefulSets annotated with certs.k8c.io/pod-secret-name.")
+	fs.StringVar(&o.ClusterDomain, "cluster-domain", o.ClusterDomain,
+		"The DNS domain of the cluster. Read from the search domains of /etc/resolv.conf if empty.")
+	fs.BoolVar(&o.PodInjector, "pod-injector", o.PodInjector,
+		"Serve the webhook mounting the Secret of the Certificate named by certs.k8c.io/inject into Pods.")
+	fs.StringVar(&o.InjectMountPath, "inject-mount-path", o.InjectMountPath,
+		"Where the Pod webhook mounts the Secret, unless set with certs.k8c.io/inject-mount-path.")
+	fs.BoolVar(&o.RolloutRestart, "rollout-restart", o.RolloutRestart,
+		"Restart the Deployments, StatefulSets and DaemonSets using a managed Secret once it is renewed.")
+	fs.DurationVar(&o.RolloutInterval.Duration, "rollout-interval", o.RolloutInterval.Duration,
+		"The minimum time between two restarts on renewal, across all workloads. Restarts are not throttled if 0.")
+	fs.StringVar(&o.ServingCertDir, "serving-cert-dir", o.ServingCertDir,
+		"The directory the webhook and metrics servers read tls.crt and tls.key from.")
+	fs.Func("serving-cert-dns-names", "Comma separated list of DNS names of the serving certificate the manager "+
+		"issues itself from its CA. The serving certificate is not issued if empty.",
+		func(v string) error {
+			o.ServingCertDNSNames = splitList(v)
+			return nil
+		})
+	fs.BoolVar(&o.MetricsSecure, "metrics-secure", o.MetricsSecure,
+		"Serve the metrics endpoint over HTTPS with the serving certificate.")
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// trustBundleSelector returns the selector of the namespaces to publish the
+// trust bundle in, or nil if it is not published.
+func (o *options) trustBundleSelector() (labels.Selector, error) {
+	if o.TrustBundleNamespaceSelector == "" {
+		return nil, nil
+	}
+
+	for _, format := range o.TrustBundleFormats {
+		if format != controller.TrustBundleFormatJKS && format != controller.TrustBundleFormatPKCS12 {
+			return nil, errors.Errorf("invalid trust bundle format %q", format)
+		}
+	}
+
+	selector, err := labels.Parse(o.TrustBundleNamespaceSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid trust bundle namespace selector")
+	}
+
+	return selector, nil
+}
+
+// trustBundlePasswordSecret returns the Secret holding the password of the
+// truststore variants of the trust bundle. There is no default password, so
+// it is required as soon as a truststore variant is published.
+func (o *options) trustBundlePasswordSecret() (types.NamespacedName, error) {
+	if o.TrustBundleNamespaceSelector == "" || len(o.TrustBundleFormats) == 0 {
+		return types.NamespacedName{}, nil
+	}
+
+	return parseObjectKey("trust-bundle-password-secret", o.TrustBundlePasswordSecret)
+}
+
+// caSecret returns the Secret the CA is persisted in, if any.
+func (o *options) caSecret() (types.NamespacedName, bool, error) {
+	if o.CASecret == "" {
+		return types.NamespacedName{}, false, nil
+	}
+
+	key, err := parseObjectKey("ca-secret", o.CASecret)
+
+	return key, err == nil, err
+}
+
+// parseObjectKey parses the namespace/name value of the named flag.
+func parseObjectKey(flag, value string) (types.NamespacedName, error) {
+	ns, name, ok := strings.Cut(value, "/")
+	if !ok || ns == "" || name == "" || strings.Contains(name, "/") {
+		return types.NamespacedName{}, errors.Errorf("invalid value %q, set --%s to namespace/name", value, flag)
+	}
+
+	return types.NamespacedName{Namespace: ns, Name: name}, nil
+}
+
+// clusterDomain returns the DNS domain of the cluster; the configured one, or
+// the one of the svc.<domain> search domain Kubernetes writes into the
+// resolv.conf of every Pod.
+func (o *options) clusterDomain(resolvConf string) (string, error) {
+	if o.ClusterDomain != "" {
+		return strings.Trim(o.ClusterDomain, "."), nil
+	}
+
+	data, err := os.ReadFile(resolvConf)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to detect the cluster domain, set --cluster-domain")
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "search" {
+			continue
+		}
+
+		for _, domain := range fields[1:] {
+			if d, ok := strings.CutPrefix(strings.Trim(domain, "."), "svc."); ok {
+				return d, nil
+			}
+		}
+	}
+
+	return "", errors.Errorf("no svc.<domain> search domain in %s, set --cluster-domain", resolvConf)
+}
+
+// zapOptions returns the logger options for the configured level and format.
+func (o *options) zapOptions() ([]zap.Opts, error) {
+	level, err := zapcore.ParseLevel(o.LogLevel)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid log level")
+	}
+
+	zapOpts := []zap.Opts{zap.Level(level)}
+	switch o.LogFormat {
+	case logFormatJSON:
+		zapOpts = append(zapOpts, zap.JSONEncoder())
+	case logFormatConsole:
+		zapOpts = append(zapOpts, zap.ConsoleEncoder())
+	default:
+		return nil, errors.Errorf("invalid log format %q", o.LogFormat)
+	}
+
+	return zapOpts, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func parse(t *testing.T, config string, args ...string) (*options, error) {
+	t.Helper()
+
+	if config != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatalf("unable to write config file: %v", err)
+		}
+		args = append([]string{"--config", path}, args...)
+	}
+
+	return parseOptions(flag.NewFlagSet("manager", flag.ContinueOnError), args)
+}
+
+func TestParseOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		opts, err := parse(t, "")
+		if err != nil {
+			t.Fatalf("unable to parse options: %v", err)
+		}
+
+		if opts.MetricsBindAddress != ":8080" || opts.LogLevel != "info" || opts.LogFormat != logFormatJSON ||
+			opts.LeaderElect || opts.GracefulShutdownTimeout.Duration != 30*time.Second || opts.RolloutRestart ||
+			opts.CAInjector {
+			t.Fatalf("unexpected defaults: %+v", opts)
+		}
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		opts, err := parse(t, "", "--leader-elect", "--namespaces", "todo, ,certs", "--log-format", "console")
+		if err != nil {
+			t.Fatalf("unable to parse options: %v", err)
+		}
+
+		if !opts.LeaderElect || opts.LogFormat != logFormatConsole || !slices.Equal(opts.Namespaces, []string{"todo", "certs"}) {
+			t.Fatalf("flags not applied: %+v", opts)
+		}
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		opts, err := parse(t, `
+metricsBindAddress: ":9090"
+logLevel: debug
+namespaces: [todo]
+gracefulShutdownTimeout: 1m
+`)
+		if err != nil {
+			t.Fatalf("unable to parse options: %v", err)
+		}
+
+		if opts.MetricsBindAddress != ":9090" || opts.LogLevel != "debug" ||
+			!slices.Equal(opts.Namespaces, []string{"todo"}) || opts.GracefulShutdownTimeout.Duration != time.Minute {
+			t.Fatalf("config file not applied: %+v", opts)
+		}
+
+		// options missing from the file keep their defaults
+		if opts.HealthProbeBindAddress != ":8081" {
+			t.Fatalf("expected the default health probe address, got %q", opts.HealthProbeBindAddress)
+		}
+	})
+
+	t.Run("flags take precedence over the config file", func(t *testing.T) {
+		opts, err := parse(t, `
+metricsBindAddress: ":9090"
+logLevel: debug
+namespaces: [todo]
+`, "--metrics-bind-address", ":9091", "--namespaces", "certs")
+		if err != nil {
+			t.Fatalf("unable to parse options: %v", err)
+		}
+
+		if opts.MetricsBindAddress != ":9091" || !slices.Equal(opts.Namespaces, []string{"certs"}) {
+			t.Fatalf("flags did not win over the config file: %+v", opts)
+		}
+
+		// options only set in the file are kept
+		if opts.LogLevel != "debug" {
+			t.Fatalf("expected the log level of the config file, got %q", opts.LogLevel)
+		}
+	})
+
+	t.Run("unknown option in the config file", func(t *testing.T) {
+		if _, err := parse(t, "metricsBindAdress: \":9090\"\n"); err == nil {
+			t.Fatal("expected the misspelled option to be rejected")
+		}
+	})
+
+	t.Run("missing config file", func(t *testing.T) {
+		if _, err := parse(t, "", "--config", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+			t.Fatal("expected the missing config file to be reported")
+		}
+	})
+}
+
+func TestTrustBundlePasswordSecret(t *testing.T) {
+	tests := []struct {
+		secret  string
+		formats []string
+		want    types.NamespacedName
+		wantErr bool
+	}{
+		{secret: "", formats: nil},
+		{secret: "certs/truststore-password", formats: []string{"jks"},
+			want: types.NamespacedName{Namespace: "certs", Name: "truststore-password"}},
+		{secret: "", formats: []string{"pkcs12"}, wantErr: true},
+		{secret: "truststore-password", formats: []string{"pkcs12"}, wantErr: true},
+		{secret: "certs/", formats: []string{"pkcs12"}, wantErr: true},
+		{secret: "certs/truststore/password", formats: []string{"pkcs12"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.secret, func(t *testing.T) {
+			opts := &options{
+				TrustBundleNamespaceSelector: "certs.k8c.io/trust=true",
+				TrustBundleFormats:           tt.formats,
+				TrustBundlePasswordSecret:    tt.secret,
+			}
+
+			got, err := opts.trustBundlePasswordSecret()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestZapOptions(t *testing.T) {
+	tests := []struct {
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{level: "debug", format: logFormatJSON},
+		{level: "info", format: logFormatConsole},
+		{level: "warn", format: logFormatJSON},
+		{level: "error", format: logFormatJSON},
+		{level: "verbose", format: logFormatJSON, wantErr: true},
+		{level: "info", format: "text", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level+"/"+tt.format, func(t *testing.T) {
+			opts := &options{LogLevel: tt.level, LogFormat: tt.format}
+
+			zapOpts, err := opts.zapOptions()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var applied zap.Options
+			for _, opt := range zapOpts {
+				opt(&applied)
+			}
+
+			level, _ := zapcore.ParseLevel(tt.level)
+			if !applied.Level.Enabled(level) || applied.Level.Enabled(level-1) {
+				t.Fatalf("expected level %s", level)
+			}
+
+			if encoder := fmt.Sprintf("%T", applied.Encoder); !strings.Contains(strings.ToLower(encoder), tt.format) {
+				t.Fatalf("expected a %s encoder, got %s", tt.format, encoder)
+			}
+		})
+	}
+}
+
+func TestClusterDomain(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		resolvConf string
+		noFile     bool
+		want       string
+		wantErr    bool
+	}{
+		{name: "configured", configured: "cluster.local", noFile: true, want: "cluster.local"},
+		{name: "configured with dots", configured: ".example.org.", noFile: true, want: "example.org"},
+		{
+			name:       "search domain",
+			resolvConf: "nameserver 10.96.0.10\nsearch todo.svc.cluster.local svc.cluster.local cluster.local\noptions ndots:5\n",
+			want:       "cluster.local",
+		},
+		{
+			name:       "fully qualified search domain",
+			resolvConf: "search svc.example.org. example.org.\n",
+			want:       "example.org",
+		},
+		{
+			name:       "configured wins over resolv.conf",
+			configured: "example.org",
+			resolvConf: "search svc.cluster.local\n",
+			want:       "example.org",
+		},
+		{name: "no svc search domain", resolvConf: "nameserver 1.1.1.1\nsearch example.org\n", wantErr: true},
+		{name: "empty resolv.conf", resolvConf: "", wantErr: true},
+		{name: "no resolv.conf", noFile: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resolv.conf")
+			if !tt.noFile {
+				if err := os.WriteFile(path, []byte(tt.resolvConf), 0o600); err != nil {
+					t.Fatalf("unable to write resolv.conf: %v", err)
+				}
+			}
+
+			opts := &options{ClusterDomain: tt.configured}
+			got, err := opts.clusterDomain(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
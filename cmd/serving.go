@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"certificate-manager/internal/cert"
+)
+
+const (
+	// servingCertValidForDays is the lifetime of the serving certificate.
+	servingCertValidForDays = 30
+
+	// servingCertName and servingKeyName are the file names the webhook
+	// and metrics servers of controller-runtime read by default.
+	servingCertName = "tls.crt"
+	servingKeyName  = "tls.key"
+)
+
+// servingCert issues the serving certificate of the manager from its CA,
+// and writes it into dir. The webhook and metrics servers watch the files,
+// and pick up a renewed certificate without a restart.
+type servingCert struct {
+	ca       cert.CertAuthority
+	dir      string
+	dnsNames []string
+
+	// notAfter is the expiry of the certificate last written
+	notAfter time.Time
+}
+
+// write issues a new serving certificate into dir. The manager writes the
+// first one before it starts, as the servers need it to start up.
+func (s *servingCert) write() error {
+	key, crt, err := s.ca.IssueCert(cert.Request{
+		ValidForDays: servingCertValidForDays,
+		Organization: "certificate-manager",
+		DNSName:      s.dnsNames[0],
+		AltNames:     s.dnsNames,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error issuing serving certificate")
+	}
+
+	parsed, err := cert.Decode(crt)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return errors.Wrap(err, "error creating serving certificate directory")
+	}
+
+	// the watcher rejects the mismatched pair seen between the two writes,
+	// and keeps serving the previous certificate until both are replaced
+	if err := writeFileAtomic(filepath.Join(s.dir, servingKeyName), key); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, servingCertName), crt); err != nil {
+		return err
+	}
+
+	s.notAfter = parsed.NotAfter
+
+	return nil
+}
+
+// Start renews the serving certificate once two thirds of its lifetime
+// have passed, until the context is done.
+func (s *servingCert) Start(ctx context.Context) error {
+	logger := ctrl.Log.WithName("serving-cert")
+
+	renewIn := s.renewIn()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(renewIn):
+		}
+
+		if err := s.write(); err != nil {
+			// keep serving the current certificate, and try again shortly
+			logger.Error(err, "unable to renew serving certificate")
+			renewIn = time.Minute
+			continue
+		}
+
+		logger.Info("renewed serving certificate", "notAfter", s.notAfter)
+		renewIn = s.renewIn()
+	}
+}
+
+// renewIn returns the time until two thirds of the remaining lifetime of
+// the certificate last written have passed.
+func (s *servingCert) renewIn() time.Duration {
+	return time.Until(s.notAfter) * 2 / 3
+}
+
+// NeedLeaderElection returns false, every replica serves with its own
+// certificate. With the CA persisted, they are all trusted by the same caBundle.
+func (s *servingCert) NeedLeaderElection() bool {
+	return false
+}
+
+// writeFileAtomic replaces the file with data, so that readers
+// never see a partially written file.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return errors.Wrapf(err, "error writing %s", name)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "error writing %s", name)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "error writing %s", name)
+	}
+
+	return errors.Wrapf(os.Rename(tmp.Name(), name), "error writing %s", name)
+}
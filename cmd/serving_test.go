@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"certificate-manager/internal/cert"
+	"certificate-manager/internal/cert/mocks"
+)
+
+func newServingCert(t *testing.T) *servingCert {
+	t.Helper()
+
+	ca, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	return &servingCert{
+		ca:       ca,
+		dir:      filepath.Join(t.TempDir(), "serving-certs"),
+		dnsNames: []string{"webhook-service.certs.svc", "webhook-service.certs"},
+	}
+}
+
+func TestServingCertWrite(t *testing.T) {
+	s := newServingCert(t)
+
+	if err := s.write(); err != nil {
+		t.Fatalf("unable to write serving certificate: %v", err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(filepath.Join(s.dir, servingCertName), filepath.Join(s.dir, servingKeyName))
+	if err != nil {
+		t.Fatalf("unable to load serving certificate: %v", err)
+	}
+
+	crt, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("unable to parse serving certificate: %v", err)
+	}
+	if !slices.Equal(crt.DNSNames, s.dnsNames) {
+		t.Fatalf("expected DNS names %v, got %v", s.dnsNames, crt.DNSNames)
+	}
+	if !crt.NotAfter.Equal(s.notAfter) {
+		t.Fatalf("expected the expiry %s to be recorded, got %s", crt.NotAfter, s.notAfter)
+	}
+	if lifetime := crt.NotAfter.Sub(crt.NotBefore); lifetime != servingCertValidForDays*24*time.Hour {
+		t.Fatalf("expected a lifetime of %d days, got %s", servingCertValidForDays, lifetime)
+	}
+
+	caCrt, err := cert.Decode(s.ca.CACert())
+	if err != nil {
+		t.Fatalf("unable to decode CA certificate: %v", err)
+	}
+	if err := crt.CheckSignatureFrom(caCrt); err != nil {
+		t.Fatalf("expected the serving certificate to be issued by the CA: %v", err)
+	}
+
+	// the key is private to the manager
+	info, err := os.Stat(filepath.Join(s.dir, servingKeyName))
+	if err != nil {
+		t.Fatalf("unable to stat key: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		t.Fatalf("expected the key not to be readable by others, got %v", perm)
+	}
+}
+
+func TestServingCertStartRenews(t *testing.T) {
+	s := newServingCert(t)
+	if err := s.write(); err != nil {
+		t.Fatalf("unable to write serving certificate: %v", err)
+	}
+
+	certFile := filepath.Join(s.dir, servingCertName)
+	initial, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("unable to read serving certificate: %v", err)
+	}
+
+	// two thirds of the remaining lifetime is when it is renewed
+	s.notAfter = time.Now().Add(1500 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+
+	time.Sleep(500 * time.Millisecond)
+	if current, _ := os.ReadFile(certFile); !bytes.Equal(current, initial) {
+		cancel()
+		t.Fatal("expected the serving certificate not to be renewed before two thirds of its lifetime")
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		current, _ := os.ReadFile(certFile)
+		if !bytes.Equal(current, initial) {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("expected the serving certificate to be renewed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Start to return once the context is done")
+	}
+}
+
+func TestServingCertStartRetries(t *testing.T) {
+	// a failed renewal is retried a minute later, not right away
+	ca := mocks.NewMockCertAuthority(gomock.NewController(t))
+	ca.EXPECT().IssueCert(gomock.Any()).Times(1).Return(nil, nil, errors.New("CA unavailable"))
+
+	s := &servingCert{
+		ca:       ca,
+		dir:      t.TempDir(),
+		dnsNames: []string{"webhook-service.certs.svc"},
+		notAfter: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "tls.crt")
+
+	for _, data := range [][]byte{[]byte("first"), []byte("second")} {
+		if err := writeFileAtomic(name, data); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("unable to read file: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("expected %q, got %q", data, got)
+		}
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the written file, got %d entries", len(entries))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "tls.crt"), []byte("data")); err == nil {
+		t.Fatal("expected writing into a missing directory to fail")
+	}
+}
@@ -1,8 +1,10 @@
 package cert
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"net"
@@ -19,17 +21,18 @@ const (
 type certAuthority struct {
 	key          *rsa.PrivateKey
 	cert         *x509.Certificate
+	encodedCert  []byte
 	countries    []string
 	ipAddrs      []net.IP
 	validForDays time.Duration
+	revoked      *revocationList
+
+	// crlURLs are the CRL distribution points of issued certificates.
+	crlURLs []string
 }
 
-func newCertAuthority() (*certAuthority, error) {
-	ca := &certAuthority{
-		countries:    []string{"DE", "IN", "US"},
-		ipAddrs:      []net.IP{net.ParseIP("127.0.0.1")},
-		validForDays: validDays,
-	}
+func newCertAuthority(opts ...Option) (*certAuthority, error) {
+	ca := emptyCertAuthority(opts...)
 
 	err := ca.newCredentials()
 	if err != nil {
@@ -39,9 +42,39 @@ func newCertAuthority() (*certAuthority, error) {
 	return ca, nil
 }
 
+func loadCertAuthority(key, crt []byte, opts ...Option) (*certAuthority, error) {
+	ca := emptyCertAuthority(opts...)
+
+	err := ca.loadCredentials(key, crt)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading CA")
+	}
+
+	return ca, nil
+}
+
+func emptyCertAuthority(opts ...Option) *certAuthority {
+	ca := &certAuthority{
+		countries:    []string{"DE", "IN", "US"},
+		ipAddrs:      []net.IP{net.ParseIP("127.0.0.1")},
+		validForDays: validDays,
+		revoked:      &revocationList{},
+	}
+	for _, opt := range opts {
+		opt(ca)
+	}
+
+	return ca
+}
+
 // IssueCert creates a new self-signed x509 certificate.
 // Returns base64 encoded key and certificate; error otherwise.
 func (ca certAuthority) IssueCert(req Request) ([]byte, []byte, error) {
+	// reject requests that violate the issuing policy
+	if err := validateRequest(req); err != nil {
+		return nil, nil, err
+	}
+
 	// generate an RSA key-pair
 	key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
 	if err != nil {
@@ -80,3 +113,38 @@ func (ca certAuthority) HasCertificateExpired(cert []byte) (bool, error) {
 
 	return crt.NotAfter.Before(time.Now()), nil
 }
+
+// CACert returns the base64 encoded certificate of the CA.
+func (ca certAuthority) CACert() []byte {
+	return ca.encodedCert
+}
+
+// Ping verifies that the CA is loaded, valid and able to sign.
+func (ca certAuthority) Ping() error {
+	if ca.key == nil || ca.cert == nil {
+		return errors.New("CA credentials are not loaded")
+	}
+
+	now := time.Now()
+	if now.Before(ca.cert.NotBefore) || now.After(ca.cert.NotAfter) {
+		return errors.Errorf("CA certificate is not valid at %s", now.Format(time.RFC3339))
+	}
+
+	// sign and verify a probe, which is far cheaper than issuing a certificate
+	digest := sha256.Sum256([]byte(now.String()))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ca.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return errors.Wrap(err, "error signing with the CA key")
+	}
+
+	pub, ok := ca.cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("CA certificate does not hold an RSA public key")
+	}
+
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		return errors.Wrap(err, "CA key does not match the CA certificate")
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package cert
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoadAuthority(t *testing.T) {
+	key, crt, err := NewAuthorityCredentials()
+	if err != nil {
+		t.Fatalf("unable to create CA credentials: %v", err)
+	}
+
+	ca, err := LoadAuthority(key, crt)
+	if err != nil {
+		t.Fatalf("unable to load CA: %v", err)
+	}
+	if !bytes.Equal(ca.CACert(), crt) {
+		t.Fatal("loaded CA certificate does not match the persisted one")
+	}
+	if err := ca.Ping(); err != nil {
+		t.Fatalf("loaded CA is not able to sign: %v", err)
+	}
+
+	// certificates issued by one load of the CA are trusted by the next
+	_, issued, err := ca.IssueCert(Request{Organization: "k8c", DNSName: "test.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	reloaded, err := LoadAuthority(key, crt)
+	if err != nil {
+		t.Fatalf("unable to reload CA: %v", err)
+	}
+	if err := reloaded.Revoke(issued); err != nil {
+		t.Fatalf("expected the reloaded CA to be the issuer: %v", err)
+	}
+
+	otherKey, otherCrt, err := NewAuthorityCredentials()
+	if err != nil {
+		t.Fatalf("unable to create CA credentials: %v", err)
+	}
+
+	_, leaf, err := ca.IssueCert(Request{Organization: "k8c", DNSName: "test.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key, crt []byte
+	}{
+		{name: "mismatched key", key: otherKey, crt: crt},
+		{name: "not a CA certificate", key: key, crt: leaf},
+		{name: "no key", key: nil, crt: otherCrt},
+		{name: "no certificate", key: key, crt: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadAuthority(tt.key, tt.crt); err == nil {
+				t.Fatal("expected the credentials to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"strconv"
+	"time"
+	"unicode"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/pkg/errors"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// jksCertType is the type of the certificates in JKS keystores.
+	jksCertType = "X.509"
+
+	// pbkdf2Iterations is the PBKDF2 iteration count of encrypted private keys.
+	pbkdf2Iterations = 100000
+
+	// KeystoreAlias is the alias of the entries in the generated keystores.
+	KeystoreAlias = "certificate"
+)
+
+// Keypair holds the parsed credentials of an issued certificate.
+type Keypair struct {
+	Key   interface{}
+	Cert  *x509.Certificate
+	Chain []*x509.Certificate
+}
+
+// DecodeKeypair parses the base64 encoded private key and certificate
+// chain. The first certificate of the chain is the leaf certificate.
+func DecodeKeypair(key, chain []byte) (*Keypair, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	pk, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := DecodeAll(chain)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	return &Keypair{Key: pk, Cert: certs[0], Chain: certs[1:]}, nil
+}
+
+// parsePrivateKey parses a PKCS#1 or PKCS#8 encoded private key.
+func parsePrivateKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case typeRSAKey:
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "error decoding PKCS#1 private key")
+	case typePKCS8Key:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "error decoding PKCS#8 private key")
+	default:
+		return nil, errors.Errorf("unsupported private key type %q", block.Type)
+	}
+}
+
+// DecodeAll parses all PEM encoded certificates in the given bytes.
+func DecodeAll(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != typeCert {
+			continue
+		}
+
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding DER certificate bytes")
+		}
+		certs = append(certs, crt)
+	}
+}
+
+// PKCS8 returns the DER encoded PKCS#8 form of the private key.
+func (kp *Keypair) PKCS8() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(kp.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding PKCS#8 private key")
+	}
+
+	return der, nil
+}
+
+// EncryptedPKCS8 returns the PEM encoded PKCS#8 private key, encrypted
+// with the given password using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func (kp *Keypair) EncryptedPKCS8(password string) ([]byte, error) {
+	der, err := pkcs8.MarshalPrivateKey(kp.Key, []byte(password), &pkcs8.Opts{
+		Cipher: pkcs8.AES256CBC,
+		KDFOpts: pkcs8.PBKDF2Opts{
+			SaltSize:       16,
+			IterationCount: pbkdf2Iterations,
+			HMACHash:       crypto.SHA256,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error encrypting PKCS#8 private key")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: typeEncryptedKey, Bytes: der}), nil
+}
+
+// PKCS12 returns a PKCS#12 keystore holding the keypair and its chain,
+// encrypted with the given password.
+func (kp *Keypair) PKCS12(password string) ([]byte, error) {
+	data, err := pkcs12.Modern.Encode(kp.Key, kp.Cert, kp.Chain, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding PKCS#12 keystore")
+	}
+
+	return data, nil
+}
+
+// PKCS12TrustStore returns a PKCS#12 truststore holding the given
+// certificates, protected with the given password.
+func PKCS12TrustStore(certs []*x509.Certificate, password string) ([]byte, error) {
+	data, err := pkcs12.Modern.EncodeTrustStore(certs, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding PKCS#12 truststore")
+	}
+
+	return data, nil
+}
+
+// JKS returns a Java keystore holding the keypair and its chain,
+// protected with the given password.
+func (kp *Keypair) JKS(password string) ([]byte, error) {
+	der, err := kp.PKCS8()
+	if err != nil {
+		return nil, err
+	}
+
+	passwd, err := jksPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]keystore.Certificate, 0, 1+len(kp.Chain))
+	for _, crt := range append([]*x509.Certificate{kp.Cert}, kp.Chain...) {
+		chain = append(chain, keystore.Certificate{Type: jksCertType, Content: crt.Raw})
+	}
+
+	ks := keystore.New()
+	if err := ks.SetPrivateKeyEntry(KeystoreAlias, keystore.PrivateKeyEntry{
+		CreationTime:     time.Now(),
+		PrivateKey:       der,
+		CertificateChain: chain,
+	}, passwd); err != nil {
+		return nil, errors.Wrap(err, "error encoding JKS private key entry")
+	}
+
+	return storeJKS(ks, passwd)
+}
+
+// JKSTrustStore returns a Java keystore holding the given certificates
+// as trusted entries, protected with the given password.
+func JKSTrustStore(certs []*x509.Certificate, password string) ([]byte, error) {
+	passwd, err := jksPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := keystore.New(keystore.WithOrderedAliases())
+	for i, crt := range certs {
+		alias := KeystoreAlias
+		if i > 0 {
+			alias = KeystoreAlias + "-" + strconv.Itoa(i)
+		}
+
+		if err := ks.SetTrustedCertificateEntry(alias, keystore.TrustedCertificateEntry{
+			CreationTime: time.Now(),
+			Certificate:  keystore.Certificate{Type: jksCertType, Content: crt.Raw},
+		}); err != nil {
+			return nil, errors.Wrap(err, "error encoding JKS trusted certificate entry")
+		}
+	}
+
+	return storeJKS(ks, passwd)
+}
+
+// storeJKS returns the encoded keystore, signed with the password.
+func storeJKS(ks keystore.KeyStore, passwd []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, passwd); err != nil {
+		return nil, errors.Wrap(err, "error encoding JKS keystore")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// jksPassword returns the password as keystore-go expects it, one byte per
+// Java char. Java chars are UTF-16, so only Latin-1 passwords, whose code
+// points fit a single byte, are encoded the way keytool reads them.
+func jksPassword(password string) ([]byte, error) {
+	out := make([]byte, 0, len(password))
+	for _, r := range password {
+		if r > unicode.MaxLatin1 {
+			return nil, errors.Errorf("JKS passwords are limited to Latin-1 characters, got %q", r)
+		}
+		out = append(out, byte(r))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,205 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestEncryptedPKCS8RoundTrip(t *testing.T) {
+	ca, err := Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	key, crt, err := ca.IssueCert(Request{Organization: "k8c", DNSName: "test.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	kp, err := DecodeKeypair(key, crt)
+	if err != nil {
+		t.Fatalf("unable to decode keypair: %v", err)
+	}
+
+	encrypted, err := kp.EncryptedPKCS8("changeit")
+	if err != nil {
+		t.Fatalf("unable to encrypt private key: %v", err)
+	}
+
+	block, _ := pem.Decode(encrypted)
+	if block == nil || block.Type != typeEncryptedKey {
+		t.Fatalf("expected a PEM block of type %q", typeEncryptedKey)
+	}
+
+	decrypted, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte("changeit"))
+	if err != nil {
+		t.Fatalf("unable to decrypt private key: %v", err)
+	}
+
+	if !kp.Key.(*rsa.PrivateKey).Equal(decrypted) {
+		t.Fatal("decrypted private key does not match the original")
+	}
+
+	if _, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte("wrong")); err == nil {
+		t.Fatal("expected decryption with a wrong password to fail")
+	}
+}
+
+// issueKeypair issues a certificate for test.k8c.io, and returns it with
+// the CA certificate as its chain.
+func issueKeypair(t *testing.T) *Keypair {
+	t.Helper()
+
+	ca, err := Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	key, crt, err := ca.IssueCert(Request{Organization: "k8c", DNSName: "test.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	kp, err := DecodeKeypair(key, append(crt, ca.CACert()...))
+	if err != nil {
+		t.Fatalf("unable to decode keypair: %v", err)
+	}
+	if len(kp.Chain) != 1 {
+		t.Fatalf("expected the CA certificate in the chain, got %d certificates", len(kp.Chain))
+	}
+
+	return kp
+}
+
+func TestPKCS12RoundTrip(t *testing.T) {
+	kp := issueKeypair(t)
+
+	keystore, err := kp.PKCS12("changeit")
+	if err != nil {
+		t.Fatalf("unable to encode keystore: %v", err)
+	}
+
+	key, crt, chain, err := pkcs12.DecodeChain(keystore, "changeit")
+	if err != nil {
+		t.Fatalf("unable to decode keystore: %v", err)
+	}
+	if !kp.Key.(*rsa.PrivateKey).Equal(key) {
+		t.Fatal("private key does not match the original")
+	}
+	if !crt.Equal(kp.Cert) {
+		t.Fatal("certificate does not match the original")
+	}
+	if len(chain) != 1 || !chain[0].Equal(kp.Chain[0]) {
+		t.Fatal("chain does not match the original")
+	}
+
+	if _, _, _, err := pkcs12.DecodeChain(keystore, "wrong"); err == nil {
+		t.Fatal("expected decoding with a wrong password to fail")
+	}
+
+	truststore, err := PKCS12TrustStore(kp.Chain, "changeit")
+	if err != nil {
+		t.Fatalf("unable to encode truststore: %v", err)
+	}
+
+	trusted, err := pkcs12.DecodeTrustStore(truststore, "changeit")
+	if err != nil {
+		t.Fatalf("unable to decode truststore: %v", err)
+	}
+	if len(trusted) != 1 || !trusted[0].Equal(kp.Chain[0]) {
+		t.Fatal("trusted certificates do not match the original")
+	}
+}
+
+// loadJKS decodes the keystore with keystore-go, which verifies the
+// integrity digest the way keytool does.
+func loadJKS(t *testing.T, data []byte, password string) keystore.KeyStore {
+	t.Helper()
+
+	ks := keystore.New()
+	if err := ks.Load(bytes.NewReader(data), []byte(password)); err != nil {
+		t.Fatalf("unable to load keystore: %v", err)
+	}
+
+	return ks
+}
+
+func TestJKSPassword(t *testing.T) {
+	// one byte per Java char; Latin-1 maps to the same UTF-16 code unit
+	if got, err := jksPassword("pä"); err != nil || !bytes.Equal(got, []byte{'p', 0xe4}) {
+		t.Fatalf("expected %x, got %x, err: %v", []byte{'p', 0xe4}, got, err)
+	}
+
+	if _, err := jksPassword("p\U0001F600"); err == nil {
+		t.Fatal("expected a password beyond Latin-1 to be rejected")
+	}
+}
+
+func TestJKSRoundTrip(t *testing.T) {
+	kp := issueKeypair(t)
+
+	data, err := kp.JKS("changeit")
+	if err != nil {
+		t.Fatalf("unable to encode keystore: %v", err)
+	}
+
+	ks := loadJKS(t, data, "changeit")
+	if aliases := ks.Aliases(); len(aliases) != 1 || aliases[0] != KeystoreAlias {
+		t.Fatalf("expected a single entry %q, got %v", KeystoreAlias, aliases)
+	}
+
+	entry, err := ks.GetPrivateKeyEntry(KeystoreAlias, []byte("changeit"))
+	if err != nil {
+		t.Fatalf("unable to recover key: %v", err)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(entry.PrivateKey)
+	if err != nil {
+		t.Fatalf("unable to parse recovered key: %v", err)
+	}
+	if !kp.Key.(*rsa.PrivateKey).Equal(key) {
+		t.Fatal("private key does not match the original")
+	}
+
+	chain := entry.CertificateChain
+	if len(chain) != 2 || chain[0].Type != "X.509" ||
+		!bytes.Equal(chain[0].Content, kp.Cert.Raw) || !bytes.Equal(chain[1].Content, kp.Chain[0].Raw) {
+		t.Fatal("certificate chain does not match the original")
+	}
+
+	if _, err := ks.GetPrivateKeyEntry(KeystoreAlias, []byte("wrong")); err == nil {
+		t.Fatal("expected the key not to be recovered with the wrong password")
+	}
+	if err := keystore.New().Load(bytes.NewReader(data), []byte("wrong")); err == nil {
+		t.Fatal("expected the keystore not to load with the wrong password")
+	}
+
+	data, err = JKSTrustStore([]*x509.Certificate{kp.Chain[0], kp.Cert}, "changeit")
+	if err != nil {
+		t.Fatalf("unable to encode truststore: %v", err)
+	}
+
+	ks = loadJKS(t, data, "changeit")
+	for alias, want := range map[string]*x509.Certificate{
+		KeystoreAlias:        kp.Chain[0],
+		KeystoreAlias + "-1": kp.Cert,
+	} {
+		entry, err := ks.GetTrustedCertificateEntry(alias)
+		if err != nil {
+			t.Fatalf("unable to get trusted certificate %q: %v", alias, err)
+		}
+		if !bytes.Equal(entry.Certificate.Content, want.Raw) {
+			t.Fatalf("trusted certificate %q does not match the original", alias)
+		}
+	}
+	if aliases := ks.Aliases(); len(aliases) != 2 {
+		t.Fatalf("expected two entries, got %v", aliases)
+	}
+}
@@ -39,6 +39,35 @@ func (m *MockCertAuthority) EXPECT() *MockCertAuthorityMockRecorder {
 	return m.recorder
 }
 
+// CACert mocks base method.
+func (m *MockCertAuthority) CACert() []byte {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CACert")
+	ret0, _ := ret[0].([]byte)
+	return ret0
+}
+
+// CACert indicates an expected call of CACert.
+func (mr *MockCertAuthorityMockRecorder) CACert() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CACert", reflect.TypeOf((*MockCertAuthority)(nil).CACert))
+}
+
+// CRL mocks base method.
+func (m *MockCertAuthority) CRL() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CRL")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CRL indicates an expected call of CRL.
+func (mr *MockCertAuthorityMockRecorder) CRL() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CRL", reflect.TypeOf((*MockCertAuthority)(nil).CRL))
+}
+
 // HasCertificateExpired mocks base method.
 func (m *MockCertAuthority) HasCertificateExpired(arg0 []byte) (bool, error) {
 	m.ctrl.T.Helper()
@@ -69,3 +98,45 @@ func (mr *MockCertAuthorityMockRecorder) IssueCert(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueCert", reflect.TypeOf((*MockCertAuthority)(nil).IssueCert), arg0)
 }
+
+// LoadRevocations mocks base method.
+func (m *MockCertAuthority) LoadRevocations(arg0 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadRevocations", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LoadRevocations indicates an expected call of LoadRevocations.
+func (mr *MockCertAuthorityMockRecorder) LoadRevocations(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadRevocations", reflect.TypeOf((*MockCertAuthority)(nil).LoadRevocations), arg0)
+}
+
+// Ping mocks base method.
+func (m *MockCertAuthority) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockCertAuthorityMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockCertAuthority)(nil).Ping))
+}
+
+// Revoke mocks base method.
+func (m *MockCertAuthority) Revoke(arg0 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockCertAuthorityMockRecorder) Revoke(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockCertAuthority)(nil).Revoke), arg0)
+}
@@ -0,0 +1,117 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	typeCRL = "X509 CRL"
+
+	// crlValidity is the time until the next CRL is due.
+	crlValidity = 24 * time.Hour
+)
+
+// revocationList holds the certificates revoked by the CA. It is kept in
+// memory; a persisted CA is handed the revocations of other replicas and
+// earlier runs with LoadRevocations.
+type revocationList struct {
+	mu      sync.Mutex
+	entries []x509.RevocationListEntry
+	number  int64
+}
+
+// Revoke adds the given base64 encoded certificate to the revocation list.
+// Revoking a certificate more than once has no effect.
+func (ca certAuthority) Revoke(crt []byte) error {
+	parsed, err := Decode(crt)
+	if err != nil {
+		return err
+	}
+
+	if err := parsed.CheckSignatureFrom(ca.cert); err != nil {
+		return errors.Wrap(ErrUnknownIssuer, err.Error())
+	}
+
+	ca.revoked.mu.Lock()
+	defer ca.revoked.mu.Unlock()
+
+	for _, entry := range ca.revoked.entries {
+		if entry.SerialNumber.Cmp(parsed.SerialNumber) == 0 {
+			return nil
+		}
+	}
+
+	ca.revoked.entries = append(ca.revoked.entries, x509.RevocationListEntry{
+		SerialNumber:   parsed.SerialNumber,
+		RevocationTime: time.Now(),
+	})
+
+	return nil
+}
+
+// CRL returns the base64 encoded certificate revocation list of the CA,
+// signed by the CA.
+func (ca certAuthority) CRL() ([]byte, error) {
+	ca.revoked.mu.Lock()
+	defer ca.revoked.mu.Unlock()
+
+	ca.revoked.number++
+	now := time.Now()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificateEntries: ca.revoked.entries,
+		Number:                    big.NewInt(ca.revoked.number),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+	}, ca.cert, ca.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the revocation list")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: typeCRL, Bytes: der}), nil
+}
+
+// LoadRevocations adds the certificates revoked in the given base64 encoded
+// revocation list to the revocation list. The list must be signed by the CA.
+func (ca certAuthority) LoadRevocations(crl []byte) error {
+	block, _ := pem.Decode(crl)
+	if block == nil || block.Type != typeCRL {
+		return errors.New("no PEM encoded revocation list found")
+	}
+
+	list, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "error decoding the revocation list")
+	}
+	if err := list.CheckSignatureFrom(ca.cert); err != nil {
+		return errors.Wrap(ErrUnknownIssuer, err.Error())
+	}
+
+	ca.revoked.mu.Lock()
+	defer ca.revoked.mu.Unlock()
+
+	for _, entry := range list.RevokedCertificateEntries {
+		if !slices.ContainsFunc(ca.revoked.entries, func(e x509.RevocationListEntry) bool {
+			return e.SerialNumber.Cmp(entry.SerialNumber) == 0
+		}) {
+			ca.revoked.entries = append(ca.revoked.entries, x509.RevocationListEntry{
+				SerialNumber:   entry.SerialNumber,
+				RevocationTime: entry.RevocationTime,
+			})
+		}
+	}
+
+	// the numbers of the lists of the CA keep increasing
+	if list.Number != nil && list.Number.IsInt64() && list.Number.Int64() > ca.revoked.number {
+		ca.revoked.number = list.Number.Int64()
+	}
+
+	return nil
+}
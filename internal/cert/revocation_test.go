@@ -0,0 +1,127 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRevoke(t *testing.T) {
+	ca, err := Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	_, crt, err := ca.IssueCert(Request{Organization: "k8c", DNSName: "test.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	// revoking twice lists the certificate once
+	for i := 0; i < 2; i++ {
+		if err := ca.Revoke(crt); err != nil {
+			t.Fatalf("unable to revoke certificate: %v", err)
+		}
+	}
+
+	data, err := ca.CRL()
+	if err != nil {
+		t.Fatalf("unable to create revocation list: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != typeCRL {
+		t.Fatalf("expected a PEM block of type %q", typeCRL)
+	}
+
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("unable to parse revocation list: %v", err)
+	}
+
+	caCrt, _ := Decode(ca.CACert())
+	if err := crl.CheckSignatureFrom(caCrt); err != nil {
+		t.Fatalf("revocation list is not signed by the CA: %v", err)
+	}
+
+	issued, _ := Decode(crt)
+	if len(crl.RevokedCertificateEntries) != 1 ||
+		crl.RevokedCertificateEntries[0].SerialNumber.Cmp(issued.SerialNumber) != 0 {
+		t.Fatalf("expected only serial %x to be revoked", issued.SerialNumber)
+	}
+
+	other, err := Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	if err := other.Revoke(crt); err == nil {
+		t.Fatal("expected revoking a certificate of another CA to fail")
+	}
+}
+
+func TestLoadRevocations(t *testing.T) {
+	key, crt, err := NewAuthorityCredentials()
+	if err != nil {
+		t.Fatalf("unable to create CA credentials: %v", err)
+	}
+
+	// two loads of the same CA, as two replicas or two runs
+	first, _ := LoadAuthority(key, crt, WithCRLDistributionPoint("http://crl.k8c.io/ca.crl"))
+	second, _ := LoadAuthority(key, crt)
+
+	_, issued, err := first.IssueCert(Request{Organization: "k8c", DNSName: "test.k8c.io", ValidForDays: 1})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	parsed, _ := Decode(issued)
+	if len(parsed.CRLDistributionPoints) != 1 || parsed.CRLDistributionPoints[0] != "http://crl.k8c.io/ca.crl" {
+		t.Fatalf("expected the CRL distribution point to be set, got %v", parsed.CRLDistributionPoints)
+	}
+
+	if err := first.Revoke(issued); err != nil {
+		t.Fatalf("unable to revoke certificate: %v", err)
+	}
+	persisted, err := first.CRL()
+	if err != nil {
+		t.Fatalf("unable to create revocation list: %v", err)
+	}
+
+	// loading twice lists the certificate once
+	for i := 0; i < 2; i++ {
+		if err := second.LoadRevocations(persisted); err != nil {
+			t.Fatalf("unable to load revocations: %v", err)
+		}
+	}
+
+	data, err := second.CRL()
+	if err != nil {
+		t.Fatalf("unable to create revocation list: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("unable to parse revocation list: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 ||
+		crl.RevokedCertificateEntries[0].SerialNumber.Cmp(parsed.SerialNumber) != 0 {
+		t.Fatalf("expected serial %x to be revoked", parsed.SerialNumber)
+	}
+	if crl.Number.Int64() <= 1 {
+		t.Fatalf("expected the number to continue from the loaded list, got %d", crl.Number)
+	}
+
+	other, err := Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+	if err := other.LoadRevocations(persisted); !errors.Is(err, ErrUnknownIssuer) {
+		t.Fatalf("expected the list of another CA to be rejected, got %v", err)
+	}
+	if err := second.LoadRevocations([]byte("not a list")); err == nil {
+		t.Fatal("expected an invalid list to be rejected")
+	}
+}
@@ -1,5 +1,20 @@
 package cert
 
+import (
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPolicyViolation is returned when a request is rejected by the
+// issuing policy of the certificate authority.
+var ErrPolicyViolation = errors.New("request violates issuing policy")
+
+// ErrUnknownIssuer is returned for certificates not issued by the
+// certificate authority, such as those issued by a replaced CA.
+var ErrUnknownIssuer = errors.New("certificate was not issued by this CA")
+
 // CertAuthority defines a certificate authority.
 type CertAuthority interface {
 	// IssueCert issues a self-signed x509 certificate.
@@ -9,6 +24,34 @@ type CertAuthority interface {
 	// HasCertificateExpired checks whether given base64 encoded
 	// certificate has expired or not.
 	HasCertificateExpired([]byte) (bool, error)
+
+	// CACert returns the base64 encoded certificate of the CA.
+	CACert() []byte
+
+	// Ping verifies that the CA is loaded, valid and able to sign.
+	Ping() error
+
+	// Revoke adds the given base64 encoded certificate to the
+	// revocation list of the CA.
+	Revoke([]byte) error
+
+	// CRL returns the base64 encoded certificate revocation list of the CA.
+	CRL() ([]byte, error)
+
+	// LoadRevocations adds the certificates revoked in the given base64
+	// encoded revocation list, signed by the CA, to its revocation list.
+	LoadRevocations([]byte) error
+}
+
+// Option configures a Certificate Authority.
+type Option func(*certAuthority)
+
+// WithCRLDistributionPoint sets the URL the revocation list of the CA is
+// served at on the certificates it issues.
+func WithCRLDistributionPoint(url string) Option {
+	return func(ca *certAuthority) {
+		ca.crlURLs = append(ca.crlURLs, url)
+	}
 }
 
 // Request holds the required fields for generating a certificate.
@@ -17,9 +60,56 @@ type Request struct {
 	Organization string
 	DNSName      string
 	AltNames     []string
+
+	// IPAddresses are added to the loopback address every certificate holds.
+	IPAddresses []string
+
+	// URIs are the URI subject alternate names.
+	URIs []string
+
+	// ExtKeyUsages restricts the usages of the certificate; any if empty.
+	ExtKeyUsages []x509.ExtKeyUsage
 }
 
 // Authority initializes and returns a Certificate Authority.
-func Authority() (CertAuthority, error) {
-	return newCertAuthority()
+func Authority(opts ...Option) (CertAuthority, error) {
+	return newCertAuthority(opts...)
+}
+
+// NewAuthorityCredentials creates the credentials of a new Certificate
+// Authority, to be persisted and loaded with LoadAuthority.
+// Returns base64 encoded key and certificate; error otherwise.
+func NewAuthorityCredentials() ([]byte, []byte, error) {
+	ca, err := newCertAuthority()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := encodePKCS1PrivateKey(ca.key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error encoding CA private key")
+	}
+
+	return key, ca.encodedCert, nil
+}
+
+// LoadAuthority returns the Certificate Authority holding the given
+// base64 encoded key and certificate.
+func LoadAuthority(key, crt []byte, opts ...Option) (CertAuthority, error) {
+	return loadCertAuthority(key, crt, opts...)
+}
+
+// Decode parses the first PEM encoded certificate in the given bytes.
+func Decode(crt []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(crt)
+	if block == nil || block.Type != typeCert {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding DER certificate bytes")
+	}
+
+	return parsed, nil
 }
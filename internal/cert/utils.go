@@ -9,6 +9,9 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"math/big"
+	"net"
+	"net/url"
+	"slices"
 	"time"
 
 	"github.com/pkg/errors"
@@ -17,8 +20,10 @@ import (
 const (
 	shiftBits = 128
 
-	typeRSAKey = "RSA PRIVATE KEY"
-	typeCert   = "CERTIFICATE"
+	typeRSAKey       = "RSA PRIVATE KEY"
+	typePKCS8Key     = "PRIVATE KEY"
+	typeEncryptedKey = "ENCRYPTED PRIVATE KEY"
+	typeCert         = "CERTIFICATE"
 )
 
 // newCredentials creates new CA credentials.
@@ -35,13 +40,68 @@ func (ca *certAuthority) newCredentials() error {
 	}
 
 	// self sign root CA
-	_, cert, err := ca.signCertificate(tmpl, tmpl, &key.PublicKey, key)
+	encoded, cert, err := ca.signCertificate(tmpl, tmpl, &key.PublicKey, key)
 	if err != nil {
 		return errors.Wrap(err, "error signing the x509 certificate")
 	}
 
 	ca.key = key
 	ca.cert = cert
+	ca.encodedCert = encoded
+
+	return nil
+}
+
+// loadCredentials loads the given base64 encoded CA credentials.
+func (ca *certAuthority) loadCredentials(key, crt []byte) error {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return errors.New("no PEM encoded private key found")
+	}
+
+	pk, err := parsePrivateKey(block)
+	if err != nil {
+		return err
+	}
+
+	rsaKey, ok := pk.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("CA private key is not an RSA key")
+	}
+
+	cert, err := Decode(crt)
+	if err != nil {
+		return err
+	}
+
+	if !cert.IsCA {
+		return errors.New("certificate is not a CA certificate")
+	}
+	if !rsaKey.PublicKey.Equal(cert.PublicKey) {
+		return errors.New("private key does not match the CA certificate")
+	}
+
+	ca.key = rsaKey
+	ca.cert = cert
+	ca.encodedCert, err = encodeX509(cert)
+
+	return err
+}
+
+// validateRequest checks the request against the issuing policy.
+// IP addresses must parse, and URIs must be absolute.
+func validateRequest(req Request) error {
+	for _, ip := range req.IPAddresses {
+		if net.ParseIP(ip) == nil {
+			return errors.Wrapf(ErrPolicyViolation, "invalid IP address %q", ip)
+		}
+	}
+
+	for _, uri := range req.URIs {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() {
+			return errors.Wrapf(ErrPolicyViolation, "invalid URI %q", uri)
+		}
+	}
 
 	return nil
 }
@@ -56,7 +116,7 @@ func (ca certAuthority) certTemplate(req Request, isCA bool) (*x509.Certificate,
 
 	keyUsage := x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
 	if isCA {
-		keyUsage |= x509.KeyUsageCertSign
+		keyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	}
 
 	tmpl := &x509.Certificate{
@@ -80,7 +140,24 @@ func (ca certAuthority) certTemplate(req Request, isCA bool) (*x509.Certificate,
 	}
 
 	if !isCA {
-		tmpl.IPAddresses = ca.ipAddrs
+		tmpl.IPAddresses = append([]net.IP{}, ca.ipAddrs...)
+		for _, addr := range req.IPAddresses {
+			ip := net.ParseIP(addr)
+			if !slices.ContainsFunc(tmpl.IPAddresses, ip.Equal) {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+			}
+		}
+
+		for _, uri := range req.URIs {
+			u, err := url.Parse(uri)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid URI %q", uri)
+			}
+			tmpl.URIs = append(tmpl.URIs, u)
+		}
+
+		tmpl.ExtKeyUsage = req.ExtKeyUsages
+		tmpl.CRLDistributionPoints = ca.crlURLs
 	}
 
 	return tmpl, nil
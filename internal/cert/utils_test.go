@@ -0,0 +1,76 @@
+package cert
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRequestIPAddresses(t *testing.T) {
+	ca := emptyCertAuthority()
+
+	tests := []struct {
+		name    string
+		ips     []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", want: []string{"127.0.0.1"}},
+		{name: "IPv4", ips: []string{"10.0.0.1"}, want: []string{"127.0.0.1", "10.0.0.1"}},
+		{name: "IPv6", ips: []string{"fd00::1"}, want: []string{"127.0.0.1", "fd00::1"}},
+		{name: "loopback once", ips: []string{"127.0.0.1", "10.0.0.1"}, want: []string{"127.0.0.1", "10.0.0.1"}},
+		{name: "duplicates", ips: []string{"10.0.0.1", "10.0.0.1"}, want: []string{"127.0.0.1", "10.0.0.1"}},
+		{name: "IPv4-mapped IPv6", ips: []string{"::ffff:10.0.0.1", "10.0.0.1"}, want: []string{"127.0.0.1", "10.0.0.1"}},
+		{name: "hostname", ips: []string{"todo.example.org"}, wantErr: true},
+		{name: "CIDR", ips: []string{"10.0.0.0/8"}, wantErr: true},
+		{name: "empty", ips: []string{""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{Organization: "k8c", DNSName: "todo.k8c.io", IPAddresses: tt.ips}
+
+			err := validateRequest(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrPolicyViolation) {
+					t.Fatalf("expected a policy violation, got %v", err)
+				}
+				return
+			}
+
+			tmpl, err := ca.certTemplate(req, false)
+			if err != nil {
+				t.Fatalf("unable to create template: %v", err)
+			}
+
+			got := make([]string, 0, len(tmpl.IPAddresses))
+			for _, ip := range tmpl.IPAddresses {
+				got = append(got, ip.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected IP addresses %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// CA certificates hold no IP addresses
+	tmpl, err := ca.certTemplate(Request{IPAddresses: []string{"10.0.0.1"}}, true)
+	if err != nil {
+		t.Fatalf("unable to create template: %v", err)
+	}
+	if len(tmpl.IPAddresses) != 0 {
+		t.Fatalf("expected no IP addresses on the CA, got %v", tmpl.IPAddresses)
+	}
+
+	// the template does not share the IP addresses of the CA
+	tmpl, _ = ca.certTemplate(Request{IPAddresses: []string{"10.0.0.1"}}, false)
+	tmpl.IPAddresses[0] = net.ParseIP("192.0.2.1")
+	if !ca.ipAddrs[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatal("expected the CA IP addresses not to be modified")
+	}
+}
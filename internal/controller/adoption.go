@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert"
+)
+
+// reasons for the SecretConflict condition
+const (
+	conflictOwnedByOther        = "OwnedByOther"
+	conflictCredentialsMismatch = "CredentialsMismatch"
+)
+
+// ensureSecret makes sure the referenced Secret holds credentials for the
+// Certificate. It creates the Secret if there is none, adopts an existing one
+// holding matching credentials, and otherwise replaces it as allowed by the
+// secret conflict policy.
+func (rh *requestHandler) ensureSecret(ctx context.Context, obj *certsv1.Certificate) (time.Duration, error) {
+	key := client.ObjectKey{
+		Namespace: obj.Namespace,
+		Name:      obj.Spec.SecretRef.Name,
+	}
+
+	// the cache only holds managed Secrets; read from the API server
+	// to find those created by someone else
+	var existing corev1.Secret
+	err := rh.reader.Get(ctx, key, &existing)
+	if apierrors.IsNotFound(err) {
+		return rh.issueSecret(ctx, obj, nil, nil)
+	}
+	if err != nil {
+		return reconcileShortly, err
+	}
+
+	owner := metav1.GetControllerOf(&existing)
+	owned := owner != nil && owner.UID == obj.UID
+	if owner != nil && !owned {
+		return rh.reportConflict(ctx, obj, conflictOwnedByOther,
+			fmt.Sprintf("secret %s is controlled by %s %s", key.Name, owner.Kind, owner.Name))
+	}
+
+	// expired credentials are never adopted; everything else is
+	// adopted as long as it matches the spec and the current CA
+	var mismatch error
+	if obj.Status.State != certsv1.StateExpired {
+		if mismatch = rh.validateSecret(&existing, obj); mismatch == nil {
+			return rh.adoptSecret(ctx, obj, &existing)
+		}
+	}
+
+	// rotate the credentials of our own Secret in place
+	if owned {
+		return rh.issueSecret(ctx, obj, &existing, nil)
+	}
+
+	switch obj.Spec.SecretConflictPolicy {
+	case certsv1.SecretConflictPolicyOverwrite:
+		return rh.replaceSecret(ctx, obj, &existing)
+	case certsv1.SecretConflictPolicyAdopt:
+		return rh.issueSecret(ctx, obj, &existing, &existing)
+	default:
+		msg := fmt.Sprintf("secret %s already exists and does not hold matching credentials", key.Name)
+		if mismatch != nil {
+			msg = fmt.Sprintf("%s: %v", msg, mismatch)
+		}
+
+		return rh.reportConflict(ctx, obj, conflictCredentialsMismatch, msg)
+	}
+}
+
+// validateSecret checks whether the Secret holds a matching keypair, issued
+// by the current CA for the spec of the Certificate, that has not expired.
+func (rh *requestHandler) validateSecret(sec *corev1.Secret, obj *certsv1.Certificate) error {
+	keys := secretKeysOf(obj)
+	if !keys.matches(sec) {
+		return errors.Errorf("secret is not of type %s holding %s and %s", keys.secretType(), keys.key, keys.cert)
+	}
+
+	if _, err := tls.X509KeyPair(sec.Data[keys.cert], sec.Data[keys.key]); err != nil {
+		return errors.Wrap(err, "invalid keypair")
+	}
+
+	crt, err := getX509Certificate(sec.Data[keys.cert])
+	if err != nil {
+		return err
+	}
+
+	caCrt, err := cert.Decode(rh.ca.CACert())
+	if err != nil {
+		return errors.Wrap(err, "invalid CA certificate")
+	}
+
+	if err := crt.CheckSignatureFrom(caCrt); err != nil {
+		return errors.Wrap(err, "certificate was not issued by the current CA")
+	}
+
+	expired, err := rh.ca.HasCertificateExpired(sec.Data[keys.cert])
+	if err != nil {
+		return err
+	}
+	if expired {
+		return errors.New("certificate has expired")
+	}
+
+	var extCert certsv1.Certificate
+	if err := getCertFromExternalWorld(sec, keys.cert, &extCert); err != nil {
+		return err
+	}
+
+	if certificateHasChanges(obj, &extCert) {
+		return errors.New("certificate does not match the spec")
+	}
+
+	return nil
+}
+
+// adoptSecret takes ownership of a Secret holding matching credentials.
+func (rh *requestHandler) adoptSecret(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret) (time.Duration, error) {
+
+	patch := client.MergeFrom(sec.DeepCopy())
+	if err := controllerutil.SetControllerReference(obj, sec, rh.client.Scheme()); err != nil {
+		return reconcileShortly, err
+	}
+
+	if sec.Labels == nil {
+		sec.Labels = map[string]string{}
+	}
+	sec.Labels[labelManaged] = "true"
+
+	if err := rh.client.Patch(ctx, sec, patch); err != nil {
+		return reconcileShortly, err
+	}
+
+	if crt, err := getX509Certificate(sec.Data[secretKeysOf(obj).cert]); err == nil {
+		rh.recorder.Eventf(obj, corev1.EventTypeNormal, reasonAdopted,
+			"adopted existing secret %s: %s", sec.Name, describe(crt))
+	}
+
+	return rh.markValid(ctx, obj)
+}
+
+// replaceSecret deletes the Secret and issues a new one in its place.
+func (rh *requestHandler) replaceSecret(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret) (time.Duration, error) {
+
+	if err := rh.client.Delete(ctx, sec, client.Preconditions{UID: &sec.UID}); client.IgnoreNotFound(err) != nil {
+		return reconcileShortly, err
+	}
+
+	return rh.issueSecret(ctx, obj, nil, nil)
+}
+
+// issueSecret writes freshly issued credentials into the Secret, updating
+// current in place if given. The labels and annotations of keep, if given,
+// are carried over.
+func (rh *requestHandler) issueSecret(ctx context.Context,
+	obj *certsv1.Certificate, current *corev1.Secret, keep *corev1.Secret) (time.Duration, error) {
+
+	sec, crt, err := rh.newSecret(ctx, obj, keep)
+	if err != nil {
+		return reconcileInAMinute, err
+	}
+
+	revision := nextRevision(obj, current)
+	if err := rh.writeSecret(ctx, obj, sec, current, revision); err != nil {
+		return reconcileShortly, err
+	}
+
+	rh.recordIssued(obj, crt)
+
+	obj.Status.Revision = revision
+
+	return rh.markValid(ctx, obj)
+}
+
+// markValid records the Certificate as holding valid credentials.
+// A previous Secret that cannot be released yet is retried later on.
+func (rh *requestHandler) markValid(ctx context.Context, obj *certsv1.Certificate) (time.Duration, error) {
+	meta.RemoveStatusCondition(&obj.Status.Conditions, certsv1.ConditionSecretConflict)
+	meta.RemoveStatusCondition(&obj.Status.Conditions, certsv1.ConditionExpiringSoon)
+
+	if err := rh.releasePreviousSecret(ctx, obj); err != nil {
+		rh.logger.Error(err, "unable to release previous secret", "name", obj.Status.SecretName)
+	}
+
+	obj.Status.State = certsv1.StateValid
+	if err := rh.client.Status().Update(ctx, obj); err != nil {
+		return reconcileShortly, err
+	}
+
+	return reconcileNone, nil
+}
+
+// reportConflict records an unresolved conflict with an existing Secret,
+// and checks back later, since unmanaged Secrets are not watched.
+func (rh *requestHandler) reportConflict(ctx context.Context,
+	obj *certsv1.Certificate, reason, msg string) (time.Duration, error) {
+
+	rh.recorder.Event(obj, corev1.EventTypeWarning, reasonSecretConflict, msg)
+
+	meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+		Type:               certsv1.ConditionSecretConflict,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: obj.Generation,
+	})
+
+	obj.Status.State = certsv1.StateConflict
+	if err := rh.client.Status().Update(ctx, obj); err != nil {
+		return reconcileShortly, err
+	}
+
+	return reconcileInAMinute, nil
+}
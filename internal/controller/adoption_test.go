@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert/mocks"
+)
+
+// issueBackdated returns a CA certificate, and a key and certificate issued
+// by it for test.k8c.io, with the given lifetime, that were issued age ago.
+func issueBackdated(t *testing.T, age, lifetime time.Duration) (caPEM, keyPEM, crtPEM []byte) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate CA key: %v", err)
+	}
+
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"certificate-manager"}},
+		NotBefore:             time.Now().Add(-age),
+		NotAfter:              time.Now().Add(lifetime),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("unable to create CA certificate: %v", err)
+	}
+	caCrt, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	notBefore := time.Now().Add(-age)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test.k8c.io", Organization: []string{"k8c"}},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCrt, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestEnsureSecretAdoptsRestoredSecret(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		name         string
+		validForDays int
+		state        certsv1.State
+	}{
+		{
+			name:         "lifetime matches the spec",
+			validForDays: 365,
+			state:        certsv1.StateValid,
+		},
+		{
+			name:         "lifetime differs from the spec",
+			validForDays: 90,
+			state:        certsv1.StateConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// restored from a backup taken ten days after issuance
+			caPEM, keyPEM, crtPEM := issueBackdated(t, 10*day, 365*day)
+
+			ca := mocks.NewMockCertAuthority(gomock.NewController(t))
+			ca.EXPECT().CACert().AnyTimes().Return(caPEM)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = certsv1.AddToScheme(scheme)
+
+			obj := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{Name: "todo-app", Namespace: "todo", UID: types.UID("uid")},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					ValidForDays: tt.validForDays,
+					SecretRef:    certsv1.SecretRef{Name: "todo-app"},
+				},
+			}
+			sec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "todo-app", Namespace: "todo"},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSPrivateKeyKey: keyPEM,
+					corev1.TLSCertKey:       crtPEM,
+				},
+			}
+
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(obj, sec).
+				WithStatusSubresource(obj).
+				Build()
+			rh := newRequestHandler(logr.Discard(), c, c, record.NewFakeRecorder(10), ca)
+
+			if _, err := rh.ensureSecret(context.Background(), obj); err != nil {
+				t.Fatalf("unable to ensure secret: %v", err)
+			}
+
+			if obj.Status.State != tt.state {
+				t.Fatalf("expected state %s, got %s", tt.state, obj.Status.State)
+			}
+
+			var adopted corev1.Secret
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(sec), &adopted); err != nil {
+				t.Fatalf("unable to get secret: %v", err)
+			}
+			if owned := metav1.IsControlledBy(&adopted, obj); owned != (tt.state == certsv1.StateValid) {
+				t.Fatalf("expected the secret to be adopted only if valid, adopted: %t", owned)
+			}
+		})
+	}
+}
+
+func TestGetValidForDays(t *testing.T) {
+	const day = 24 * time.Hour
+
+	// the lifetime counts, not the time left
+	for _, age := range []time.Duration{0, day, 100 * day, 400 * day} {
+		notBefore := time.Now().Add(-age)
+		crt := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(365 * day)}
+
+		if got := getValidForDays(crt); got != 365 {
+			t.Errorf("expected 365 days for a certificate issued %s ago, got %d", age, got)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert"
+)
+
+const (
+	// annotationInjectCAFrom names the Certificate, as namespace/name,
+	// whose CA is injected into the CA bundles of the annotated object.
+	annotationInjectCAFrom = "certs.k8c.io/inject-ca-from"
+
+	// annotationInjectManagerCA marks the webhook configurations of the
+	// manager itself, which are injected with the CA of the manager.
+	annotationInjectManagerCA = "certs.k8c.io/inject-manager-ca"
+
+	// injectCAFromField indexes injectable objects by their Certificate.
+	injectCAFromField = ".metadata.annotations.inject-ca-from"
+)
+
+// The kinds of objects holding CA bundles the CA can be injected into.
+var (
+	ValidatingWebhookConfigurationKind = schema.GroupVersionKind{
+		Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingWebhookConfiguration"}
+	MutatingWebhookConfigurationKind = schema.GroupVersionKind{
+		Group: "admissionregistration.k8s.io", Version: "v1", Kind: "MutatingWebhookConfiguration"}
+	CustomResourceDefinitionKind = schema.GroupVersionKind{
+		Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+	APIServiceKind = schema.GroupVersionKind{
+		Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"}
+
+	// InjectableKinds lists all kinds the CA can be injected into.
+	InjectableKinds = []schema.GroupVersionKind{
+		ValidatingWebhookConfigurationKind,
+		MutatingWebhookConfigurationKind,
+		CustomResourceDefinitionKind,
+		APIServiceKind,
+	}
+)
+
+// CAInjectorReconciler keeps the CA bundles of objects of one kind, annotated
+// with certs.k8c.io/inject-ca-from, in sync with the CA of the Certificate.
+// Objects annotated with certs.k8c.io/inject-manager-ca get the CA itself,
+// which issued the serving certificate of the manager.
+type CAInjectorReconciler struct {
+	client.Client
+
+	CA cert.CertAuthority
+
+	// Kind is one of InjectableKinds.
+	Kind schema.GroupVersionKind
+}
+
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations;mutatingwebhookconfigurations,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=apiregistration.k8s.io,resources=apiservices,verbs=get;list;watch;update;patch
+
+func (r *CAInjectorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+
+	obj := r.newObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var bundle []byte
+	ref := obj.GetAnnotations()[annotationInjectCAFrom]
+	switch {
+	case obj.GetAnnotations()[annotationInjectManagerCA] == "true":
+		ref = "manager"
+		bundle = r.CA.CACert()
+	case ref != "":
+		var err error
+		if bundle, err = r.caBundle(ctx, ref); err != nil {
+			logger.Info("unable to get CA to inject", "from", ref, "reason", err.Error())
+
+			// the Certificate and its Secret are watched; try again once they show up
+			return reconcile.Result{}, nil
+		}
+	default:
+		return reconcile.Result{}, nil
+	}
+
+	changed, err := injectCABundle(r.Kind, obj, base64.StdEncoding.EncodeToString(bundle))
+	if err != nil || !changed {
+		return reconcile.Result{}, err
+	}
+
+	logger.Info("injecting CA bundle", "kind", r.Kind.Kind, "name", obj.GetName(), "from", ref)
+
+	return reconcile.Result{}, r.Update(ctx, obj)
+}
+
+// caBundle returns the CA certificate stored in the Secret of the
+// Certificate referenced as namespace/name.
+func (r *CAInjectorReconciler) caBundle(ctx context.Context, ref string) ([]byte, error) {
+	ns, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		return nil, errors.Errorf("invalid certificate reference %q, expected namespace/name", ref)
+	}
+
+	var crt certsv1.Certificate
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &crt); err != nil {
+		return nil, err
+	}
+
+	var sec corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ns, Name: crt.Spec.SecretRef.Name}, &sec); err != nil {
+		return nil, err
+	}
+
+	bundle := sec.Data[secretKeysOf(&crt).ca]
+	if len(bundle) == 0 {
+		return nil, errors.Errorf("secret %s holds no CA certificate", sec.Name)
+	}
+
+	return bundle, nil
+}
+
+// injectCABundle sets the CA bundles of the object, as found for its kind,
+// and returns whether any of them changed.
+func injectCABundle(kind schema.GroupVersionKind, obj *unstructured.Unstructured, bundle string) (bool, error) {
+	switch kind {
+	case ValidatingWebhookConfigurationKind, MutatingWebhookConfigurationKind:
+		webhooks, _, err := unstructured.NestedSlice(obj.Object, "webhooks")
+		if err != nil {
+			return false, err
+		}
+
+		changed := false
+		for i := range webhooks {
+			webhook, ok := webhooks[i].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			current, _, _ := unstructured.NestedString(webhook, "clientConfig", "caBundle")
+			if current == bundle {
+				continue
+			}
+
+			if err := unstructured.SetNestedField(webhook, bundle, "clientConfig", "caBundle"); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+
+		if !changed {
+			return false, nil
+		}
+
+		return true, unstructured.SetNestedSlice(obj.Object, webhooks, "webhooks")
+	case CustomResourceDefinitionKind:
+		// only conversion webhooks have a CA bundle
+		strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "conversion", "strategy")
+		if strategy != "Webhook" {
+			return false, nil
+		}
+
+		return setCABundle(obj, bundle, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+	case APIServiceKind:
+		// the API server rejects a CA bundle on an APIService skipping TLS verification
+		if insecure, _, _ := unstructured.NestedBool(obj.Object, "spec", "insecureSkipTLSVerify"); insecure {
+			return false, nil
+		}
+
+		return setCABundle(obj, bundle, "spec", "caBundle")
+	default:
+		return false, errors.Errorf("unsupported kind %s", kind)
+	}
+}
+
+// setCABundle sets the CA bundle at the given path, and returns whether it changed.
+func setCABundle(obj *unstructured.Unstructured, bundle string, fields ...string) (bool, error) {
+	current, _, _ := unstructured.NestedString(obj.Object, fields...)
+	if current == bundle {
+		return false, nil
+	}
+
+	return true, unstructured.SetNestedField(obj.Object, bundle, fields...)
+}
+
+func (r *CAInjectorReconciler) newObject() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(r.Kind)
+
+	return obj
+}
+
+// indexInjectCAFrom indexes injectable objects by the Certificate they reference.
+func indexInjectCAFrom(obj client.Object) []string {
+	if ref := obj.GetAnnotations()[annotationInjectCAFrom]; ref != "" {
+		return []string{ref}
+	}
+
+	return nil
+}
+
+// objectsForCertificate maps a Certificate to the objects its CA is injected into.
+func (r *CAInjectorReconciler) objectsForCertificate(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(r.Kind.GroupVersion().WithKind(r.Kind.Kind + "List"))
+	if err := r.List(ctx, list,
+		client.MatchingFields{injectCAFromField: obj.GetNamespace() + "/" + obj.GetName()},
+	); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: item.GetName()},
+		})
+	}
+
+	return requests
+}
+
+// objectsForSecret maps a Secret to the objects the CA of its Certificates
+// is injected into.
+func (r *CAInjectorReconciler) objectsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var crts certsv1.CertificateList
+	if err := r.List(ctx, &crts,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{secretRefNameField: obj.GetName()},
+	); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range crts.Items {
+		requests = append(requests, r.objectsForCertificate(ctx, &crts.Items[i])...)
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the injector for its kind. It relies on the
+// Certificate index set up by the CertificateReconciler.
+func (r *CAInjectorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		r.newObject(), injectCAFromField, indexInjectCAFrom); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("cainjector-"+strings.ToLower(r.Kind.Kind)).
+		For(r.newObject(), builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetAnnotations()[annotationInjectCAFrom] != "" ||
+				obj.GetAnnotations()[annotationInjectManagerCA] == "true"
+		}))).
+		Watches(&certsv1.Certificate{},
+			handler.EnqueueRequestsFromMapFunc(r.objectsForCertificate),
+		).
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.objectsForSecret),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
+		Complete(r)
+}
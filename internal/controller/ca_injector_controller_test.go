@@ -0,0 +1,257 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"encoding/base64"
+
+	"go.uber.org/mock/gomock"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	certsv1 "certificate-manager/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CA Injector Controller", func() {
+	Context("When a webhook configuration references a certificate", func() {
+		It("Should keep its CA bundle in sync", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "webhook.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			webhook := &admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "test-webhook-",
+					Annotations: map[string]string{
+						"certs.k8c.io/inject-ca-from": ns.Name + "/" + certificateName,
+					},
+				},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+					Name: "validate.webhook.k8c.io",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						URL: ptr.To("https://localhost:9443/validate"),
+					},
+					SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+					AdmissionReviewVersions: []string{"v1"},
+				}},
+			}
+			Expect(k8sClient.Create(ctx, webhook)).Should(Succeed())
+
+			key := types.NamespacedName{Name: webhook.Name}
+			Eventually(func() []byte {
+				_ = k8sClient.Get(ctx, key, webhook)
+				return webhook.Webhooks[0].ClientConfig.CABundle
+			}, timeout, interval).Should(Equal(caCrt))
+
+			// manual edits are reverted
+			webhook.Webhooks[0].ClientConfig.CABundle = []byte("edited")
+			Expect(k8sClient.Update(ctx, webhook)).Should(Succeed())
+
+			Eventually(func() []byte {
+				_ = k8sClient.Get(ctx, key, webhook)
+				return webhook.Webhooks[0].ClientConfig.CABundle
+			}, timeout, interval).Should(Equal(caCrt))
+
+			Expect(k8sClient.Delete(ctx, webhook)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+
+	Context("When a CRD conversion webhook references a certificate", func() {
+		It("Should inject the CA bundle", func() {
+			ns := createInjectedCertificate()
+
+			crd := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apiextensions.k8s.io/v1",
+				"kind":       "CustomResourceDefinition",
+				"metadata": map[string]interface{}{
+					"name": "widgets.injector.k8c.io",
+					"annotations": map[string]interface{}{
+						"certs.k8c.io/inject-ca-from": ns.Name + "/" + certificateName,
+					},
+				},
+				"spec": map[string]interface{}{
+					"group": "injector.k8c.io",
+					"scope": "Namespaced",
+					"names": map[string]interface{}{
+						"plural":   "widgets",
+						"singular": "widget",
+						"kind":     "Widget",
+						"listKind": "WidgetList",
+					},
+					"versions": []interface{}{
+						map[string]interface{}{
+							"name":    "v1",
+							"served":  true,
+							"storage": true,
+							"schema": map[string]interface{}{
+								"openAPIV3Schema": map[string]interface{}{"type": "object"},
+							},
+						},
+					},
+					"conversion": map[string]interface{}{
+						"strategy": "Webhook",
+						"webhook": map[string]interface{}{
+							"clientConfig": map[string]interface{}{
+								"url": "https://localhost:9443/convert",
+							},
+							"conversionReviewVersions": []interface{}{"v1"},
+						},
+					},
+				},
+			}}
+			Expect(k8sClient.Create(ctx, crd)).Should(Succeed())
+
+			Eventually(injectedCABundle(crd, "spec", "conversion", "webhook", "clientConfig", "caBundle"),
+				timeout, interval).Should(Equal(caCrt))
+
+			Expect(k8sClient.Delete(ctx, crd)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+
+	Context("When an APIService references a certificate", func() {
+		It("Should keep its CA bundle in sync", func() {
+			ns := createInjectedCertificate()
+
+			apiService := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apiregistration.k8s.io/v1",
+				"kind":       "APIService",
+				"metadata": map[string]interface{}{
+					"name": "v1alpha1.injector.k8c.io",
+					"annotations": map[string]interface{}{
+						"certs.k8c.io/inject-ca-from": ns.Name + "/" + certificateName,
+					},
+				},
+				"spec": map[string]interface{}{
+					"group":                "injector.k8c.io",
+					"version":              "v1alpha1",
+					"groupPriorityMinimum": int64(1000),
+					"versionPriority":      int64(15),
+					"service": map[string]interface{}{
+						"namespace": ns.Name,
+						"name":      "injector",
+						"port":      int64(443),
+					},
+				},
+			}}
+			Expect(k8sClient.Create(ctx, apiService)).Should(Succeed())
+
+			caBundle := injectedCABundle(apiService, "spec", "caBundle")
+			Eventually(caBundle, timeout, interval).Should(Equal(caCrt))
+
+			// manual edits are reverted
+			Expect(unstructured.SetNestedField(apiService.Object,
+				base64.StdEncoding.EncodeToString([]byte("edited")), "spec", "caBundle")).Should(Succeed())
+			Expect(k8sClient.Update(ctx, apiService)).Should(Succeed())
+
+			Eventually(caBundle, timeout, interval).Should(Equal(caCrt))
+
+			Expect(k8sClient.Delete(ctx, apiService)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+
+		It("Should skip it if it skips TLS verification", func() {
+			ns := createInjectedCertificate()
+
+			apiService := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apiregistration.k8s.io/v1",
+				"kind":       "APIService",
+				"metadata": map[string]interface{}{
+					"name": "v1alpha2.injector.k8c.io",
+					"annotations": map[string]interface{}{
+						"certs.k8c.io/inject-ca-from": ns.Name + "/" + certificateName,
+					},
+				},
+				"spec": map[string]interface{}{
+					"group":                 "injector.k8c.io",
+					"version":               "v1alpha2",
+					"groupPriorityMinimum":  int64(1000),
+					"versionPriority":       int64(15),
+					"insecureSkipTLSVerify": true,
+					"service": map[string]interface{}{
+						"namespace": ns.Name,
+						"name":      "injector",
+						"port":      int64(443),
+					},
+				},
+			}}
+			Expect(k8sClient.Create(ctx, apiService)).Should(Succeed())
+
+			Consistently(injectedCABundle(apiService, "spec", "caBundle"), 2*interval, interval).Should(BeEmpty())
+
+			Expect(k8sClient.Delete(ctx, apiService)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
+
+// createInjectedCertificate creates a namespace holding a Certificate
+// whose CA is to be injected, and returns the namespace.
+func createInjectedCertificate() *corev1.Namespace {
+	ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+	ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+	}
+	Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+	cert := &certsv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      certificateName,
+			Namespace: ns.Name,
+		},
+		Spec: certsv1.CertificateSpec{
+			Organization: "k8c",
+			DNSName:      "injector.k8c.io",
+			AltNames:     []string{"localhost"},
+			SecretRef: certsv1.SecretRef{
+				Name: secretName,
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+	return ns
+}
+
+// injectedCABundle returns a function reading the decoded CA bundle at the
+// given path of the object.
+func injectedCABundle(obj *unstructured.Unstructured, fields ...string) func() []byte {
+	return func() []byte {
+		_ = k8sClient.Get(ctx, types.NamespacedName{Name: obj.GetName()}, obj)
+
+		encoded, _, _ := unstructured.NestedString(obj.Object, fields...)
+		bundle, _ := base64.StdEncoding.DecodeString(encoded)
+
+		return bundle
+	}
+}
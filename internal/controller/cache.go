@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// SecretCacheOptions restricts the Secret informer to the Secrets managed
+// by the controller and those holding keystore passwords, and strips the
+// metadata not needed for reconciling, so that large clusters do not pay
+// memory for all of their Secrets.
+func SecretCacheOptions() cache.ByObject {
+	managed, _ := labels.NewRequirement(labelManaged, selection.In, []string{"true", managedPassword})
+
+	return cache.ByObject{
+		Label: labels.NewSelector().Add(*managed),
+		Transform: func(in any) (any, error) {
+			if sec, ok := in.(*corev1.Secret); ok {
+				sec.ManagedFields = nil
+				delete(sec.Annotations, corev1.LastAppliedConfigAnnotation)
+			}
+
+			return in, nil
+		},
+	}
+}
+
+// indexSecretRef indexes Certificates by the name of their Secret.
+func indexSecretRef(obj client.Object) []string {
+	crt, ok := obj.(*certsv1.Certificate)
+	if !ok || crt.Spec.SecretRef.Name == "" {
+		return nil
+	}
+
+	return []string{crt.Spec.SecretRef.Name}
+}
+
+// indexPasswordSecretRef indexes Certificates by the names of the Secrets
+// holding the passwords of their additional output formats.
+func indexPasswordSecretRef(obj client.Object) []string {
+	crt, ok := obj.(*certsv1.Certificate)
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	for _, format := range crt.Spec.AdditionalOutputFormats {
+		if format.PasswordSecretRef != nil && !slices.Contains(names, format.PasswordSecretRef.Name) {
+			names = append(names, format.PasswordSecretRef.Name)
+		}
+	}
+
+	return names
+}
+
+// isPasswordSecret filters the Secrets labelled as holding keystore passwords.
+var isPasswordSecret = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	return obj.GetLabels()[labelManaged] == managedPassword
+})
+
+// certificatesForPasswordSecret maps a password Secret to the Certificates
+// whose additional output formats are protected with it.
+func (r *CertificateReconciler) certificatesForPasswordSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var crts certsv1.CertificateList
+	if err := r.List(ctx, &crts,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{passwordSecretRefField: obj.GetName()},
+	); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(crts.Items))
+	for _, crt := range crts.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: crt.Namespace, Name: crt.Name},
+		})
+	}
+
+	return requests
+}
+
+// certificatesForSecret maps a Secret to the Certificates referencing it.
+func (r *CertificateReconciler) certificatesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var crts certsv1.CertificateList
+	if err := r.List(ctx, &crts,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{secretRefNameField: obj.GetName()},
+	); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(crts.Items)+1)
+	for _, crt := range crts.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: crt.Namespace, Name: crt.Name},
+		})
+	}
+
+	// replicas name their Certificate in another namespace
+	if ns, name, ok := strings.Cut(obj.GetAnnotations()[annotationReplicaOf], "/"); ok {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: ns, Name: name},
+		})
+	}
+
+	return requests
+}
+
+// certificatesForNamespace maps a Namespace to the Certificates replicating
+// their Secret, which may select it for replication.
+func (r *CertificateReconciler) certificatesForNamespace(ctx context.Context, _ client.Object) []reconcile.Request {
+	var crts certsv1.CertificateList
+	if err := r.List(ctx, &crts); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, crt := range crts.Items {
+		if crt.Spec.SecretReplication == nil && len(crt.Status.ReplicaNamespaces) == 0 {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: crt.Namespace, Name: crt.Name},
+		})
+	}
+
+	return requests
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestSecretCacheSelectsPasswordSecrets(t *testing.T) {
+	selector := SecretCacheOptions().Label
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		cached   bool
+		password bool
+	}{
+		{name: "managed", labels: map[string]string{labelManaged: "true"}, cached: true},
+		{name: "password", labels: map[string]string{labelManaged: managedPassword}, cached: true, password: true},
+		{name: "other value", labels: map[string]string{labelManaged: "false"}},
+		{name: "unlabelled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selector.Matches(labels.Set(tt.labels)); got != tt.cached {
+				t.Fatalf("expected cached: %t, got %t", tt.cached, got)
+			}
+
+			sec := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Labels: tt.labels}}
+			if got := isPasswordSecret.Update(event.UpdateEvent{ObjectOld: sec, ObjectNew: sec}); got != tt.password {
+				t.Fatalf("expected a password secret: %t, got %t", tt.password, got)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -15,11 +17,14 @@ import (
 
 	certsv1 "certificate-manager/api/v1"
 	"certificate-manager/internal/cert"
+	"certificate-manager/internal/metrics"
 )
 
 type CertificateReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
 
 	CA cert.CertAuthority
 }
@@ -28,6 +33,8 @@ type CertificateReconciler struct {
 //+kubebuilder:rbac:groups=certs.k8c.io,resources=certificates/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=certs.k8c.io,resources=certificates/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;watch;create;delete;list;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
 	var (
@@ -36,12 +43,16 @@ func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		logger  = log.FromContext(ctx)
 		crt     = &certsv1.Certificate{}
 		result  = ctrl.Result{}
-		handler = newRequestHandler(logger, r.Client, r.CA)
+		handler = newRequestHandler(logger, r.Client, r.APIReader, r.Recorder, r.CA)
 	)
 
 	logger.Info("reconciling certificate resources")
 
 	if err = r.Get(ctx, req.NamespacedName, crt); err != nil {
+		if errors.IsNotFound(err) {
+			metrics.ForgetCertificate(req.Namespace, req.Name)
+		}
+
 		err = client.IgnoreNotFound(err)
 		if err != nil {
 			logger.Error(err, "unable to fetch certificate resource", "name", req.NamespacedName)
@@ -70,12 +81,29 @@ func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 }
 
 func (r *CertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&certsv1.Certificate{}, secretRefNameField, indexSecretRef); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&certsv1.Certificate{}, passwordSecretRefField, indexPasswordSecretRef); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&certsv1.Certificate{}).
 		Owns(&corev1.Secret{}).
 		Watches(&corev1.Secret{},
-			&handler.EnqueueRequestForObject{},
+			handler.EnqueueRequestsFromMapFunc(r.certificatesForSecret),
 			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
 		).
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.certificatesForPasswordSecret),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}, isPasswordSecret),
+		).
+		Watches(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.certificatesForNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		).
 		Complete(r)
 }
@@ -3,12 +3,16 @@
 package controller_test
 
 import (
+	"strings"
 	"time"
 
 	"go.uber.org/mock/gomock"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"software.sslmate.com/src/go-pkcs12"
 
 	certsv1 "certificate-manager/api/v1"
 
@@ -76,8 +80,53 @@ var _ = Describe("Certificate Controller", func() {
 			Expect(createdCrt.Spec.ValidForDays).Should(Equal(365))
 			Expect(createdCrt.Status.State).Should(Equal(certsv1.StateValid))
 
+			sec := &corev1.Secret{}
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, secKey, sec)).Should(Succeed())
+			Expect(sec.Labels).Should(HaveKeyWithValue("certs.k8c.io/managed", "true"))
+			Expect(sec.Data).Should(HaveKeyWithValue("ca.crt", caCrt))
+
 			Expect(k8sClient.Delete(ctx, createdCrt)).Should(Succeed())
 		})
+
+		It("Should record an event for the issued certificate", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			Eventually(func() bool {
+				var events corev1.EventList
+				err := k8sClient.List(ctx, &events, client.InNamespace(ns.Name))
+				if err != nil {
+					return false
+				}
+
+				for _, e := range events.Items {
+					if e.InvolvedObject.Name == certificateName && e.Reason == "Issued" {
+						return strings.Contains(e.Message, "serial")
+					}
+				}
+
+				return false
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
 	})
 
 	Context("When updating a certificate", func() {
@@ -127,4 +176,519 @@ var _ = Describe("Certificate Controller", func() {
 			Expect(k8sClient.Delete(ctx, createdCrt)).Should(Succeed())
 		})
 	})
+
+	Context("When using a secret template", func() {
+		It("Should reconcile the labels and annotations of the secret", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+					Labels:    map[string]string{"app.kubernetes.io/instance": "gitops"},
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+					SecretTemplate: &certsv1.SecretTemplate{
+						Labels: map[string]string{"team": "todo"},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			sec := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secKey, sec)
+			}, timeout, interval).Should(Succeed())
+			Expect(sec.Labels).Should(HaveKeyWithValue("team", "todo"))
+			Expect(sec.Labels).ShouldNot(HaveKey("app.kubernetes.io/instance"))
+			Expect(sec.Annotations).Should(HaveKey("certs.k8c.io/serial"))
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, key, cert)).Should(Succeed())
+			cert.Spec.SecretTemplate = nil
+			Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, secKey, sec)
+				_, ok := sec.Labels["team"]
+				return err == nil && !ok
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
+	})
+
+	Context("When rotating a certificate", func() {
+		It("Should update the secret in place", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			sec := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secKey, sec)
+			}, timeout, interval).Should(Succeed())
+			Expect(sec.Annotations).Should(HaveKeyWithValue("certs.k8c.io/revision", "1"))
+
+			// the issued certificate no longer matches the spec
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, key, cert)).Should(Succeed())
+			cert.Spec.Organization = "k8c-rotated"
+			Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+			rotated := &corev1.Secret{}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, secKey, rotated)
+				return err == nil && rotated.Annotations["certs.k8c.io/revision"] != "1"
+			}, timeout, interval).Should(BeTrue())
+			Expect(rotated.UID).Should(Equal(sec.UID))
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
+
+		It("Should keep the previous immutable revision", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+					RotationStrategy: certsv1.RotationStrategyImmutable,
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			revision := func() int64 {
+				_ = k8sClient.Get(ctx, key, cert)
+				return cert.Status.Revision
+			}
+			Eventually(revision, timeout, interval).Should(Equal(int64(1)))
+
+			// rotate twice, by changing the spec the issued certificate must match
+			for _, org := range []string{"k8c-rotated", "k8c-rotated-again"} {
+				Expect(k8sClient.Get(ctx, key, cert)).Should(Succeed())
+				want := cert.Status.Revision + 1
+				cert.Spec.Organization = org
+				Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+				Eventually(revision, timeout, interval).Should(Equal(want))
+			}
+
+			names := func() []string {
+				var revisions corev1.SecretList
+				_ = k8sClient.List(ctx, &revisions, client.InNamespace(ns.Name),
+					client.MatchingLabels{"certs.k8c.io/revision-of": secretName})
+
+				var names []string
+				for _, rev := range revisions.Items {
+					names = append(names, rev.Name)
+				}
+
+				return names
+			}
+			Eventually(names, timeout, interval).Should(ConsistOf(secretName+"-2", secretName+"-3"))
+
+			alias := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: ns.Name}, alias)).Should(Succeed())
+			Expect(alias.Annotations).Should(HaveKeyWithValue("certs.k8c.io/revision-secret", secretName+"-3"))
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
+	})
+
+	Context("When using custom secret keys", func() {
+		It("Should store the credentials under the given keys", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+					SecretKeys: &certsv1.SecretKeys{
+						PrivateKey:  "server.key",
+						Certificate: "server.crt",
+						Chain:       "fullchain.pem",
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			sec := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secKey, sec)
+			}, timeout, interval).Should(Succeed())
+			Expect(sec.Type).Should(Equal(corev1.SecretTypeOpaque))
+			Expect(sec.Data).Should(HaveKeyWithValue("server.key", tlsKey))
+			Expect(sec.Data).Should(HaveKey("server.crt"))
+			Expect(sec.Data).Should(HaveKey("ca.crt"))
+			Expect(sec.Data).Should(HaveKey("fullchain.pem"))
+			Expect(sec.Data).ShouldNot(HaveKey("tls.key"))
+
+			// going back to the default keys turns it into a TLS secret
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, key, cert)).Should(Succeed())
+			cert.Spec.SecretKeys = nil
+			Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, secKey, sec)
+				return err == nil && sec.Type == corev1.SecretTypeTLS
+			}, timeout, interval).Should(BeTrue())
+			Expect(sec.Data).ShouldNot(HaveKey("server.key"))
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
+	})
+
+	Context("When requesting additional output formats", func() {
+		It("Should write them into the secret", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			password := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "keystore-password",
+					Namespace: ns.Name,
+					Labels:    map[string]string{"certs.k8c.io/managed": "password"},
+				},
+				Data: map[string][]byte{"password": []byte("changeit")},
+			}
+			Expect(k8sClient.Create(ctx, password)).Should(Succeed())
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+					AdditionalOutputFormats: []certsv1.OutputFormat{
+						{
+							Type:              certsv1.OutputFormatPKCS12,
+							PasswordSecretRef: &certsv1.SecretKeyRef{Name: password.Name},
+						},
+						{Type: certsv1.OutputFormatDER},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			sec := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secKey, sec)
+			}, timeout, interval).Should(Succeed())
+			Expect(sec.Data).Should(HaveKey("keystore.p12"))
+			Expect(sec.Data).Should(HaveKey("truststore.p12"))
+			Expect(sec.Data).Should(HaveKey("tls.der"))
+			Expect(sec.Data).Should(HaveKey("key.der"))
+
+			// a rotated password is picked up from the watched password secret
+			password.Data["password"] = []byte("rotated")
+			Expect(k8sClient.Update(ctx, password)).Should(Succeed())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, secKey, sec); err != nil {
+					return err
+				}
+				_, _, _, err := pkcs12.DecodeChain(sec.Data["keystore.p12"], "rotated")
+				return err
+			}, timeout, interval).Should(Succeed())
+
+			// dropped formats are removed from the secret
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, key, cert)).Should(Succeed())
+			cert.Spec.AdditionalOutputFormats = cert.Spec.AdditionalOutputFormats[1:]
+			Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, secKey, sec)
+				_, ok := sec.Data["keystore.p12"]
+				return err == nil && !ok
+			}, timeout, interval).Should(BeTrue())
+			Expect(sec.Data).Should(HaveKey("tls.der"))
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
+	})
+
+	Context("When renaming the secret", func() {
+		It("Should write the new secret and delete the previous one", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, key, cert)
+				return err == nil && cert.Status.SecretName == secretName
+			}, timeout, interval).Should(BeTrue())
+
+			cert.Spec.SecretRef.Name = secretName + "-renamed"
+			Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, key, cert)
+				return err == nil && cert.Status.SecretName == secretName+"-renamed"
+			}, timeout, interval).Should(BeTrue())
+
+			sec := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName + "-renamed", Namespace: ns.Name}, sec)).
+				Should(Succeed())
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: ns.Name}, sec)
+			Expect(err).Should(HaveOccurred())
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+		})
+	})
+
+	Context("When replicating the secret", func() {
+		It("Should keep copies in the namespaces that opted in", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			target := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: certificateNamespace,
+					Labels: map[string]string{
+						"team":                           "ingress",
+						"certs.k8c.io/allow-replication": "true",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, target)).Should(Succeed())
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+					SecretReplication: &certsv1.SecretReplication{
+						NamespaceSelector: metav1.LabelSelector{
+							MatchLabels: map[string]string{"team": "ingress"},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Eventually(func() []string {
+				_ = k8sClient.Get(ctx, key, cert)
+				return cert.Status.ReplicaNamespaces
+			}, timeout, interval).Should(ConsistOf(target.Name))
+
+			sec := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: ns.Name}, sec)).
+				Should(Succeed())
+			replica := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: target.Name}, replica)).
+				Should(Succeed())
+			Expect(replica.Data).Should(Equal(sec.Data))
+
+			// opting out removes the copy
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(target), target)).Should(Succeed())
+			delete(target.Labels, "certs.k8c.io/allow-replication")
+			Expect(k8sClient.Update(ctx, target)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: target.Name}, replica)
+			}, timeout, interval).ShouldNot(Succeed())
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, target)).Should(Succeed())
+		})
+	})
+
+	Context("When deleting a certificate", func() {
+		It("Should retain the secret with the Retain deletion policy", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+					DeletionPolicy: certsv1.DeletionPolicyRetain,
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, key, cert)
+				return err == nil && cert.Status.State == certsv1.StateValid && len(cert.Finalizers) > 0
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, cert)).Should(Succeed())
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, key, cert) != nil
+			}, timeout, interval).Should(BeTrue())
+
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			sec := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secKey, sec)).Should(Succeed())
+			Expect(metav1.GetControllerOf(sec)).Should(BeNil())
+			Expect(sec.Labels).ShouldNot(HaveKey("certs.k8c.io/managed"))
+		})
+	})
+
+	Context("When the secret already exists", func() {
+		var cert *certsv1.Certificate
+		BeforeEach(func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert = &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+		})
+
+		It("Should adopt a secret holding matching credentials", func() {
+			sec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: ns.Name},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSPrivateKeyKey: tlsKey,
+					corev1.TLSCertKey:       tlsCrt,
+				},
+			}
+			Expect(k8sClient.Create(ctx, sec)).Should(Succeed())
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			createdCrt := &certsv1.Certificate{}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, key, createdCrt)
+				return err == nil && createdCrt.Status.State == certsv1.StateValid
+			}, timeout, interval).Should(BeTrue())
+
+			adopted := &corev1.Secret{}
+			secKey := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, secKey, adopted)).Should(Succeed())
+			Expect(adopted.UID).Should(Equal(sec.UID))
+			Expect(metav1.IsControlledBy(adopted, createdCrt)).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, createdCrt)).Should(Succeed())
+		})
+
+		It("Should report a conflict for a secret holding other credentials", func() {
+			sec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: ns.Name},
+				Data:       map[string][]byte{"password": []byte("secret")},
+			}
+			Expect(k8sClient.Create(ctx, sec)).Should(Succeed())
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			createdCrt := &certsv1.Certificate{}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, key, createdCrt)
+				return err == nil && createdCrt.Status.State == certsv1.StateConflict
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(meta.IsStatusConditionTrue(createdCrt.Status.Conditions,
+				certsv1.ConditionSecretConflict)).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, createdCrt)).Should(Succeed())
+		})
+	})
 })
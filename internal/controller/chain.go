@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// chainPEM returns the content of tls.crt for the leaf certificate,
+// in the chain shape requested by the Certificate.
+func chainPEM(obj *certsv1.Certificate, leaf *x509.Certificate, caCrt []byte) []byte {
+	if obj.Spec.Chain == certsv1.ChainFull {
+		return fullChainPEM(leaf, caCrt)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+}
+
+// fullChainPEM returns the leaf certificate followed by the issuing CA.
+func fullChainPEM(leaf *x509.Certificate, caCrt []byte) []byte {
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+
+	return append(chain, caCrt...)
+}
+
+// syncSecretChain repairs the certificate chain and the CA certificate
+// stored in the Secret holding leaf, for example after the chain shape
+// was changed or ca.crt went stale. Returns true if the Secret is immutable
+// and can only be repaired by reissuing its credentials.
+func (rh *requestHandler) syncSecretChain(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret, leaf *x509.Certificate) (bool, error) {
+
+	keys := secretKeysOf(obj)
+	caCrt := rh.ca.CACert()
+	chain := chainPEM(obj, leaf, caCrt)
+
+	var fullChain []byte
+	if keys.chain != "" {
+		fullChain = fullChainPEM(leaf, caCrt)
+	}
+
+	if bytes.Equal(sec.Data[keys.cert], chain) && bytes.Equal(sec.Data[keys.ca], caCrt) &&
+		bytes.Equal(sec.Data[keys.chain], fullChain) {
+		return false, nil
+	}
+
+	if sec.Immutable != nil && *sec.Immutable {
+		return true, nil
+	}
+
+	rh.logger.Info("repairing certificate chain", "name", client.ObjectKeyFromObject(sec).String())
+
+	patch := client.MergeFrom(sec.DeepCopy())
+	sec.Data[keys.cert] = chain
+	sec.Data[keys.ca] = caCrt
+	if keys.chain != "" {
+		sec.Data[keys.chain] = fullChain
+	}
+
+	return false, rh.client.Patch(ctx, sec, patch)
+}
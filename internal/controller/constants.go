@@ -8,6 +8,138 @@ const (
 	reconcileNone      = time.Duration(0)
 	tlsKey             = "tls.key"
 	tlsCert            = "tls.crt"
+	caCert             = "ca.crt"
+
+	// labelManaged marks the Secrets managed by the controller;
+	// only those are cached and watched.
+	labelManaged = "certs.k8c.io/managed"
+
+	// managedPassword is the value of labelManaged on the Secrets holding
+	// keystore passwords, which are cached and watched, but not managed.
+	managedPassword = "password"
+
+	// annotations recording the certificate held by a Secret
+	annotationIssuer   = "certs.k8c.io/issuer"
+	annotationSerial   = "certs.k8c.io/serial"
+	annotationNotAfter = "certs.k8c.io/not-after"
+	annotationSpecHash = "certs.k8c.io/spec-hash"
+
+	// annotations recording the keys applied from the secret template
+	annotationTemplateLabels      = "certs.k8c.io/template-labels"
+	annotationTemplateAnnotations = "certs.k8c.io/template-annotations"
+
+	// annotationRevision holds the revision of the credentials in a Secret.
+	annotationRevision = "certs.k8c.io/revision"
+
+	// annotationRevisionSecret names the immutable Secret holding the
+	// current revision, on the alias Secret of the Immutable rotation strategy.
+	annotationRevisionSecret = "certs.k8c.io/revision-secret"
+
+	// labelRevisionOf names the alias Secret on an immutable revision Secret.
+	labelRevisionOf = "certs.k8c.io/revision-of"
+
+	// revisionHistoryLimit is the number of previous immutable revisions kept
+	// around, so that Pods still mounting them keep working during a rollout.
+	revisionHistoryLimit = 1
+
+	// annotationOutputsHash holds a digest of the inputs of the
+	// additional output formats written to a Secret.
+	annotationOutputsHash = "certs.k8c.io/outputs-hash"
+
+	// defaultPasswordKey is the key of a keystore password in its Secret.
+	defaultPasswordKey = "password"
+
+	// labelAllowReplication opts a namespace in to receive Secret replicas.
+	labelAllowReplication = "certs.k8c.io/allow-replication"
+
+	// labelReplicaOf holds the UID of the Certificate on its Secret replicas;
+	// annotationReplicaOf holds its namespace/name.
+	labelReplicaOf      = "certs.k8c.io/replica-of"
+	annotationReplicaOf = "certs.k8c.io/replica-of"
+
+	// finalizer holds back the deletion of Certificates whose
+	// Secret is retained or revoked on deletion.
+	finalizer = "certs.k8c.io/finalizer"
+
+	// annotationIssue asks for Certificates to be created for the TLS
+	// Secrets of an Ingress.
+	annotationIssue = "certs.k8c.io/issue"
+
+	// annotationSecretName asks for a Certificate for the in-cluster DNS
+	// names of a Service, stored in the named Secret.
+	annotationSecretName = "certs.k8c.io/secret-name"
+
+	// annotationClusterIPSANs adds the cluster IPs of a Service to its Certificate.
+	annotationClusterIPSANs = "certs.k8c.io/cluster-ip-sans"
+
+	// annotationPodSecretName asks for a Certificate per replica of a
+	// StatefulSet, stored in the Secret named by the template it holds.
+	annotationPodSecretName = "certs.k8c.io/pod-secret-name"
+
+	// annotations of Pods asking for the Secret of a Certificate to be
+	// mounted: the name of the Certificate, where to mount it, into which
+	// containers, and whether to point environment variables at the files
+	annotationInject           = "certs.k8c.io/inject"
+	annotationInjectMountPath  = "certs.k8c.io/inject-mount-path"
+	annotationInjectContainers = "certs.k8c.io/inject-containers"
+	annotationInjectEnv        = "certs.k8c.io/inject-env"
+
+	// annotationRestartSecrets lists further Secrets, used by a workload other
+	// than through its Pod template, whose renewal restarts the workload.
+	annotationRestartSecrets = "certs.k8c.io/restart-secrets"
+
+	// annotationRestartOnRenewal set to false opts a workload out of being
+	// restarted once a Secret it uses is renewed.
+	annotationRestartOnRenewal = "certs.k8c.io/restart-on-renewal"
+
+	// annotationOrganization sets the organization of the Certificates
+	// created for an object.
+	annotationOrganization = "certs.k8c.io/organization"
+
+	// secretRefNameField indexes Certificates by the name of their Secret.
+	secretRefNameField = ".spec.secretRef.name"
+
+	// passwordSecretRefField indexes Certificates by the names of the
+	// Secrets holding the passwords of their additional output formats.
+	passwordSecretRefField = ".spec.additionalOutputFormats.passwordSecretRef.name"
+)
+
+// reasons for the events recorded on a Certificate
+const (
+	reasonIssued         = "Issued"
+	reasonReissued       = "Reissued"
+	reasonSpecChanged    = "SpecChanged"
+	reasonSecretMissing  = "SecretMissing"
+	reasonExpired        = "Expired"
+	reasonExpiringSoon   = "ExpiringSoon"
+	reasonPolicyDenied   = "PolicyDenied"
+	reasonCAError        = "CAError"
+	reasonAdopted        = "Adopted"
+	reasonSecretConflict = "SecretConflict"
+	reasonCAChanged      = "CAChanged"
+
+	reasonOutputFormatFailed = "OutputFormatFailed"
+	reasonRetained           = "Retained"
+	reasonRevoked            = "Revoked"
+	reasonDeletionFailed     = "DeletionFailed"
+	reasonSecretRenamed      = "SecretRenamed"
+	reasonReplicaConflict    = "ReplicaConflict"
+)
+
+// reasons for the events recorded on the objects Certificates are created for
+const (
+	reasonCertificateConflict = "CertificateConflict"
+	reasonInvalidTemplate     = "InvalidTemplate"
+)
+
+// reasons for the events recorded on the namespaces trust bundles are published in
+const (
+	reasonTrustBundleConflict = "TrustBundleConflict"
+)
+
+// reasons for the events recorded on the workloads using a Secret
+const (
+	reasonRolloutRestarted = "RolloutRestarted"
 )
 
 var isImmutable = true
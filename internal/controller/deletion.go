@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert"
+)
+
+// syncFinalizer adds the finalizer to Certificates whose Secret is retained
+// or revoked on deletion, or replicated to other namespaces, and removes it
+// from all others, whose Secret is left to the garbage collector.
+func (rh *requestHandler) syncFinalizer(ctx context.Context, obj *certsv1.Certificate) error {
+	want := obj.Spec.DeletionPolicy == certsv1.DeletionPolicyRetain ||
+		obj.Spec.DeletionPolicy == certsv1.DeletionPolicyRevokeAndDelete ||
+		obj.Spec.SecretReplication != nil || len(obj.Status.ReplicaNamespaces) > 0
+	if controllerutil.ContainsFinalizer(obj, finalizer) == want {
+		return nil
+	}
+
+	patch := client.MergeFromWithOptions(obj.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if want {
+		controllerutil.AddFinalizer(obj, finalizer)
+	} else {
+		controllerutil.RemoveFinalizer(obj, finalizer)
+	}
+
+	return rh.client.Patch(ctx, obj, patch)
+}
+
+// finalize applies the deletion policy to the Secrets of a deleted
+// Certificate and their replicas, and then lets the deletion proceed.
+// Replicas are not owned by the Certificate, so they are deleted here
+// unless retained.
+func (rh *requestHandler) finalize(ctx context.Context, obj *certsv1.Certificate) (time.Duration, error) {
+	if !controllerutil.ContainsFinalizer(obj, finalizer) {
+		return reconcileNone, nil
+	}
+
+	secrets, err := rh.ownedSecrets(ctx, obj)
+	if err != nil {
+		return reconcileShortly, err
+	}
+
+	replicas, err := rh.listReplicas(ctx, obj)
+	if err != nil {
+		return reconcileShortly, err
+	}
+	secrets = append(secrets, replicas...)
+
+	var names []string
+	switch obj.Spec.DeletionPolicy {
+	case certsv1.DeletionPolicyRetain:
+		names, err = rh.retainSecrets(ctx, obj, secrets)
+		if err == nil {
+			rh.recorder.Eventf(obj, corev1.EventTypeNormal, reasonRetained,
+				"retained secrets [%s] after deletion", strings.Join(names, ", "))
+		}
+	case certsv1.DeletionPolicyRevokeAndDelete:
+		names, err = rh.revokeSecrets(ctx, obj, secrets)
+		if err == nil {
+			rh.recorder.Eventf(obj, corev1.EventTypeNormal, reasonRevoked,
+				"revoked certificates and deleted secrets [%s]", strings.Join(names, ", "))
+		}
+	default:
+		_, err = rh.deleteSecrets(ctx, replicas)
+	}
+	if err != nil {
+		rh.recorder.Eventf(obj, corev1.EventTypeWarning, reasonDeletionFailed,
+			"unable to apply deletion policy %s: %v", obj.Spec.DeletionPolicy, err)
+
+		return reconcileShortly, err
+	}
+
+	patch := client.MergeFromWithOptions(obj.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.RemoveFinalizer(obj, finalizer)
+
+	return reconcileNone, client.IgnoreNotFound(rh.client.Patch(ctx, obj, patch))
+}
+
+// ownedSecrets returns the Secrets controlled by the Certificate; the
+// Secret it references and, with the Immutable rotation strategy, the
+// Secrets of its revisions.
+func (rh *requestHandler) ownedSecrets(ctx context.Context, obj *certsv1.Certificate) ([]corev1.Secret, error) {
+	var list corev1.SecretList
+	if err := rh.client.List(ctx, &list,
+		client.InNamespace(obj.Namespace),
+		client.MatchingLabels{labelManaged: "true"},
+	); err != nil {
+		return nil, err
+	}
+
+	var owned []corev1.Secret
+	for _, sec := range list.Items {
+		if owner := metav1.GetControllerOf(&sec); owner != nil && owner.UID == obj.UID {
+			owned = append(owned, sec)
+		}
+	}
+
+	return owned, nil
+}
+
+// retainSecrets orphans the Secrets, so that the garbage collector
+// leaves them in place, and the controller no longer manages them.
+// Returns the names of the retained Secrets.
+func (rh *requestHandler) retainSecrets(ctx context.Context,
+	obj *certsv1.Certificate, secrets []corev1.Secret) ([]string, error) {
+
+	names := make([]string, 0, len(secrets))
+	for i := range secrets {
+		sec := &secrets[i]
+
+		patch := client.MergeFrom(sec.DeepCopy())
+		if metav1.IsControlledBy(sec, obj) {
+			if err := controllerutil.RemoveControllerReference(obj, sec, rh.client.Scheme()); err != nil {
+				return nil, err
+			}
+		}
+		delete(sec.Labels, labelManaged)
+		delete(sec.Labels, labelReplicaOf)
+
+		if err := rh.client.Patch(ctx, sec, patch); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		names = append(names, sec.Namespace+"/"+sec.Name)
+	}
+
+	return names, nil
+}
+
+// revokeSecrets revokes the certificates held by the Secrets, and deletes
+// them. Certificates issued by a previous CA are no longer trusted anyway,
+// and are deleted without being revoked. Returns the names and serial
+// numbers of the Secrets whose certificates were revoked.
+func (rh *requestHandler) revokeSecrets(ctx context.Context,
+	obj *certsv1.Certificate, secrets []corev1.Secret) ([]string, error) {
+
+	keys := secretKeysOf(obj)
+
+	var revoked []string
+	for i := range secrets {
+		sec := &secrets[i]
+
+		if data, ok := sec.Data[keys.cert]; ok {
+			err := rh.ca.Revoke(data)
+			switch {
+			case errors.Is(err, cert.ErrUnknownIssuer):
+				rh.logger.Info("deleting secret issued by a previous CA without revoking it", "name", sec.Name)
+			case err != nil:
+				return nil, errors.Wrapf(err, "unable to revoke certificate of secret %s", sec.Name)
+			default:
+				if crt, err := getX509Certificate(data); err == nil {
+					revoked = append(revoked, fmt.Sprintf("%s (serial %s)", sec.Name, crt.SerialNumber.Text(16)))
+				}
+			}
+		}
+
+		if err := rh.client.Delete(ctx, sec, client.Preconditions{UID: &sec.UID}); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+
+	return revoked, nil
+}
+
+// deleteSecrets deletes the Secrets, and returns their names.
+func (rh *requestHandler) deleteSecrets(ctx context.Context, secrets []corev1.Secret) ([]string, error) {
+	names := make([]string, 0, len(secrets))
+	for i := range secrets {
+		sec := &secrets[i]
+		if err := rh.client.Delete(ctx, sec, client.Preconditions{UID: &sec.UID}); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		names = append(names, sec.Name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert"
+	"certificate-manager/internal/cert/mocks"
+)
+
+func TestFinalizeRevokesAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	_, _, current := issueBackdated(t, 0, 24*time.Hour)
+	_, _, previous := issueBackdated(t, 0, 24*time.Hour)
+
+	tests := []struct {
+		name      string
+		revokeErr error
+		deleted   bool
+		event     string
+	}{
+		{
+			name:    "revoked",
+			deleted: true,
+			event:   "Normal " + reasonRevoked + " revoked certificates and deleted secrets [todo-app (serial 2)]",
+		},
+		{
+			name:      "revocation failed",
+			revokeErr: errors.New("CA unavailable"),
+			event:     "Warning " + reasonDeletionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "todo-app",
+					Namespace:         "todo",
+					UID:               types.UID("uid"),
+					Finalizers:        []string{finalizer},
+					DeletionTimestamp: ptr.To(metav1.Now()),
+				},
+				Spec: certsv1.CertificateSpec{
+					SecretRef:      certsv1.SecretRef{Name: "todo-app"},
+					DeletionPolicy: certsv1.DeletionPolicyRevokeAndDelete,
+				},
+			}
+			owned := func(name string, crt []byte) *corev1.Secret {
+				return &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "todo",
+						Labels:    map[string]string{labelManaged: "true"},
+						OwnerReferences: []metav1.OwnerReference{{
+							APIVersion: certsv1.SchemeGroupVersion.String(),
+							Kind:       "Certificate",
+							Name:       obj.Name,
+							UID:        obj.UID,
+							Controller: ptr.To(true),
+						}},
+					},
+					Data: map[string][]byte{tlsCert: crt},
+				}
+			}
+			// the revision left behind by a previous CA is deleted, but not revoked
+			secrets := []*corev1.Secret{owned("todo-app", current), owned("todo-app-1", previous)}
+
+			ca := mocks.NewMockCertAuthority(gomock.NewController(t))
+			ca.EXPECT().Revoke(current).Return(tt.revokeErr)
+			if tt.revokeErr == nil {
+				ca.EXPECT().Revoke(previous).Return(errors.Wrap(cert.ErrUnknownIssuer, "signature mismatch"))
+			}
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = certsv1.AddToScheme(scheme)
+
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(obj, secrets[0], secrets[1]).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			rh := newRequestHandler(logr.Discard(), c, c, recorder, ca)
+
+			_, err := rh.finalize(ctx, obj)
+			if (err == nil) != tt.deleted {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, sec := range secrets {
+				err := c.Get(ctx, client.ObjectKeyFromObject(sec), &corev1.Secret{})
+				if apierrors.IsNotFound(err) != tt.deleted {
+					t.Fatalf("expected secret %s to be deleted: %t, got %v", sec.Name, tt.deleted, err)
+				}
+			}
+
+			// the Certificate is gone once its finalizer was removed
+			err = c.Get(ctx, client.ObjectKeyFromObject(obj), &certsv1.Certificate{})
+			if apierrors.IsNotFound(err) != tt.deleted {
+				t.Fatalf("expected the finalizer to be removed: %t, got %v", tt.deleted, err)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if !strings.HasPrefix(event, tt.event) {
+					t.Fatalf("expected event %q, got %q", tt.event, event)
+				}
+			default:
+				t.Fatal("expected an event")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert"
+)
+
+// recordIssued emits a Normal event for a freshly issued certificate.
+// Reissued is used when the Certificate already had credentials before.
+func (rh *requestHandler) recordIssued(obj *certsv1.Certificate, crtBytes []byte) {
+	reason := reasonIssued
+	if obj.Status.State != "" {
+		reason = reasonReissued
+	}
+
+	crt, err := getX509Certificate(crtBytes)
+	if err != nil {
+		rh.recorder.Eventf(obj, corev1.EventTypeNormal, reason,
+			"issued certificate into secret %s", obj.Spec.SecretRef.Name)
+
+		return
+	}
+
+	rh.recorder.Eventf(obj, corev1.EventTypeNormal, reason,
+		"issued certificate into secret %s: %s", obj.Spec.SecretRef.Name, describe(crt))
+}
+
+// recordIssueFailure emits a Warning event for a request the CA refused
+// or failed to serve.
+func (rh *requestHandler) recordIssueFailure(obj *certsv1.Certificate, err error) {
+	if errors.Is(err, cert.ErrPolicyViolation) {
+		rh.recorder.Eventf(obj, corev1.EventTypeWarning, reasonPolicyDenied,
+			"certificate request denied: %v", err)
+
+		return
+	}
+
+	rh.recorder.Eventf(obj, corev1.EventTypeWarning, reasonCAError,
+		"certificate authority failed to issue certificate: %v", err)
+}
+
+// describe returns the serial number and validity of a certificate
+// in a form suitable for event messages.
+func describe(crt *x509.Certificate) string {
+	return fmt.Sprintf("serial %s, valid until %s",
+		crt.SerialNumber.Text(16), crt.NotAfter.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// GatewayKind is the kind of the Gateway API Gateway. The Gateway API types
+// are not a dependency of the controller, Gateways are read unstructured.
+var GatewayKind = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "Gateway"}
+
+// gatewayListener holds the fields of a Gateway listener read by the controller.
+type gatewayListener struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname,omitempty"`
+	Protocol string `json:"protocol"`
+	TLS      *struct {
+		Mode            string `json:"mode,omitempty"`
+		CertificateRefs []struct {
+			Group     string `json:"group,omitempty"`
+			Kind      string `json:"kind,omitempty"`
+			Name      string `json:"name"`
+			Namespace string `json:"namespace,omitempty"`
+		} `json:"certificateRefs,omitempty"`
+	} `json:"tls,omitempty"`
+}
+
+// GatewayReconciler creates a Certificate for every Secret referenced by the
+// HTTPS and TLS listeners of the Gateways annotated with certs.k8c.io/issue,
+// which the CertificateReconciler then issues.
+type GatewayReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(GatewayKind)
+	if err := r.Get(ctx, req.NamespacedName, gw); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// owned Certificates are garbage collected along with the Gateway
+	if !gw.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	var (
+		desired   []*certsv1.Certificate
+		listeners map[string][]certsv1.GatewayListenerReference
+		err       error
+	)
+	if gw.GetAnnotations()[annotationIssue] == "true" {
+		if desired, listeners, err = gatewayCertificates(gw); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	requeue, err := syncShimCertificates(ctx, r.Client, r.Scheme, r.Recorder, gw, desired)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{RequeueAfter: requeue}, r.syncListeners(ctx, gw, listeners)
+}
+
+// gatewayCertificates returns a Certificate for every Secret referenced by a
+// listener of the Gateway terminating TLS, issued for the hostnames of all
+// listeners referencing the Secret, and the listeners served by each of them.
+// Listeners without a hostname, and references to other kinds or namespaces
+// are skipped.
+func gatewayCertificates(gw *unstructured.Unstructured) ([]*certsv1.Certificate,
+	map[string][]certsv1.GatewayListenerReference, error) {
+
+	items, _, err := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	hosts := map[string][]string{}
+	listeners := map[string][]certsv1.GatewayListenerReference{}
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var l gatewayListener
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, &l); err != nil {
+			return nil, nil, err
+		}
+
+		if (l.Protocol != "HTTPS" && l.Protocol != "TLS") || l.Hostname == "" || l.TLS == nil ||
+			(l.TLS.Mode != "" && l.TLS.Mode != "Terminate") {
+			continue
+		}
+
+		for _, ref := range l.TLS.CertificateRefs {
+			if ref.Group != "" || (ref.Kind != "" && ref.Kind != "Secret") ||
+				(ref.Namespace != "" && ref.Namespace != gw.GetNamespace()) {
+				continue
+			}
+
+			if _, ok := hosts[ref.Name]; !ok {
+				names = append(names, ref.Name)
+			}
+			if !slices.Contains(hosts[ref.Name], l.Hostname) {
+				hosts[ref.Name] = append(hosts[ref.Name], l.Hostname)
+			}
+			listeners[ref.Name] = append(listeners[ref.Name],
+				certsv1.GatewayListenerReference{Gateway: gw.GetName(), Listener: l.Name})
+		}
+	}
+
+	crts := make([]*certsv1.Certificate, 0, len(names))
+	for _, name := range names {
+		crts = append(crts, shimCertificate(gw, name, shimOrganization(gw), hosts[name]))
+	}
+
+	return crts, listeners, nil
+}
+
+// syncListeners records the listeners served by the Certificates controlled
+// by the Gateway in their status.
+func (r *GatewayReconciler) syncListeners(ctx context.Context, gw client.Object,
+	listeners map[string][]certsv1.GatewayListenerReference) error {
+
+	for name, refs := range listeners {
+		var crt certsv1.Certificate
+		err := r.Get(ctx, client.ObjectKey{Namespace: gw.GetNamespace(), Name: name}, &crt)
+		if apierrors.IsNotFound(err) {
+			// a newly created Certificate may not be cached yet; its
+			// creation brings the Gateway back here
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// the status is written once the Certificate was issued, which
+		// brings the Gateway back here
+		if !metav1.IsControlledBy(&crt, gw) || crt.Status.State == "" ||
+			slices.Equal(crt.Status.GatewayListeners, refs) {
+			continue
+		}
+
+		patch := client.MergeFrom(crt.DeepCopy())
+		crt.Status.GatewayListeners = refs
+		if err := r.Status().Patch(ctx, &crt, patch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GatewayAPIInstalled returns whether the Gateway CRD is installed.
+func GatewayAPIInstalled(mapper meta.RESTMapper) (bool, error) {
+	_, err := mapper.RESTMapping(GatewayKind.GroupKind(), GatewayKind.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(GatewayKind)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gateway").
+		For(gw).
+		Owns(&certsv1.Certificate{}).
+		Complete(r)
+}
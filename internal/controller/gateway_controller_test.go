@@ -0,0 +1,91 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/controller"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gateway Controller", func() {
+	BeforeEach(func() {
+		if !gatewayAPIInstalled {
+			Skip("the Gateway API CRDs are not installed")
+		}
+	})
+
+	Context("When a gateway asks for certificates", func() {
+		It("Should keep a certificate per referenced secret", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			listener := func(name, hostname string) interface{} {
+				return map[string]interface{}{
+					"name":     name,
+					"hostname": hostname,
+					"port":     int64(443),
+					"protocol": "HTTPS",
+					"tls": map[string]interface{}{
+						"certificateRefs": []interface{}{
+							map[string]interface{}{"name": secretName},
+						},
+					},
+				}
+			}
+
+			gw := &unstructured.Unstructured{}
+			gw.SetGroupVersionKind(controller.GatewayKind)
+			gw.SetName("test-gateway")
+			gw.SetNamespace(ns.Name)
+			gw.SetAnnotations(map[string]string{"certs.k8c.io/issue": "true"})
+			Expect(unstructured.SetNestedField(gw.Object, "example", "spec", "gatewayClassName")).Should(Succeed())
+			Expect(unstructured.SetNestedSlice(gw.Object, []interface{}{
+				listener("https", "todo.k8c.io"),
+				listener("https-www", "www.todo.k8c.io"),
+			}, "spec", "listeners")).Should(Succeed())
+			Expect(k8sClient.Create(ctx, gw)).Should(Succeed())
+
+			key := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			cert := &certsv1.Certificate{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cert)
+			}, timeout, interval).Should(Succeed())
+			Expect(cert.Spec.DNSName).Should(Equal("todo.k8c.io"))
+			Expect(cert.Spec.AltNames).Should(Equal([]string{"todo.k8c.io", "www.todo.k8c.io"}))
+
+			Eventually(func() []certsv1.GatewayListenerReference {
+				_ = k8sClient.Get(ctx, key, cert)
+				return cert.Status.GatewayListeners
+			}, timeout, interval).Should(Equal([]certsv1.GatewayListenerReference{
+				{Gateway: "test-gateway", Listener: "https"},
+				{Gateway: "test-gateway", Listener: "https-www"},
+			}))
+
+			// removing the annotation deletes the certificate
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: gw.GetName(), Namespace: ns.Name}, gw)).
+				Should(Succeed())
+			gw.SetAnnotations(nil)
+			Expect(k8sClient.Update(ctx, gw)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cert)
+			}, timeout, interval).ShouldNot(Succeed())
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
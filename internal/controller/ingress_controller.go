@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// IngressReconciler creates a Certificate for every TLS Secret of the
+// Ingresses annotated with certs.k8c.io/issue, which the
+// CertificateReconciler then issues.
+type IngressReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+
+func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	var ing networkingv1.Ingress
+	if err := r.Get(ctx, req.NamespacedName, &ing); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// owned Certificates are garbage collected along with the Ingress
+	if !ing.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	var desired []*certsv1.Certificate
+	if ing.Annotations[annotationIssue] == "true" {
+		desired = ingressCertificates(&ing)
+	}
+
+	requeue, err := syncShimCertificates(ctx, r.Client, r.Scheme, r.Recorder, &ing, desired)
+
+	return reconcile.Result{RequeueAfter: requeue}, err
+}
+
+// ingressCertificates returns a Certificate for every TLS Secret of the
+// Ingress, issued for the hosts of all TLS entries naming the Secret.
+func ingressCertificates(ing *networkingv1.Ingress) []*certsv1.Certificate {
+	var names []string
+	hosts := map[string][]string{}
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+
+		if _, ok := hosts[tls.SecretName]; !ok {
+			names = append(names, tls.SecretName)
+		}
+		for _, host := range tls.Hosts {
+			if !slices.Contains(hosts[tls.SecretName], host) {
+				hosts[tls.SecretName] = append(hosts[tls.SecretName], host)
+			}
+		}
+	}
+
+	var crts []*certsv1.Certificate
+	for _, name := range names {
+		if len(hosts[name]) == 0 {
+			continue
+		}
+
+		crts = append(crts, shimCertificate(ing, name, shimOrganization(ing), hosts[name]))
+	}
+
+	return crts
+}
+
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("ingress").
+		For(&networkingv1.Ingress{}).
+		Owns(&certsv1.Certificate{}).
+		Complete(r)
+}
@@ -0,0 +1,87 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ingress Controller", func() {
+	Context("When an ingress asks for certificates", func() {
+		It("Should keep a certificate per TLS secret", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			ing := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ingress",
+					Namespace:   ns.Name,
+					Annotations: map[string]string{"certs.k8c.io/issue": "true"},
+				},
+				Spec: networkingv1.IngressSpec{
+					DefaultBackend: &networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: "todo",
+							Port: networkingv1.ServiceBackendPort{Number: 80},
+						},
+					},
+					TLS: []networkingv1.IngressTLS{
+						{Hosts: []string{"todo.k8c.io", "www.todo.k8c.io"}, SecretName: secretName},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ing)).Should(Succeed())
+
+			key := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			cert := &certsv1.Certificate{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cert)
+			}, timeout, interval).Should(Succeed())
+			Expect(cert.Spec.DNSName).Should(Equal("todo.k8c.io"))
+			Expect(cert.Spec.AltNames).Should(Equal([]string{"todo.k8c.io", "www.todo.k8c.io"}))
+			Expect(cert.Spec.SecretRef.Name).Should(Equal(secretName))
+			Expect(metav1.IsControlledBy(cert, ing)).Should(BeTrue())
+
+			// the Secret is issued by the certificate controller
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, &corev1.Secret{})
+			}, timeout, interval).Should(Succeed())
+
+			// changed hosts are picked up
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ing), ing)).Should(Succeed())
+			ing.Spec.TLS[0].Hosts = []string{"todo.k8c.io"}
+			Expect(k8sClient.Update(ctx, ing)).Should(Succeed())
+
+			Eventually(func() []string {
+				_ = k8sClient.Get(ctx, key, cert)
+				return cert.Spec.AltNames
+			}, timeout, interval).Should(Equal([]string{"todo.k8c.io"}))
+
+			// removing the annotation deletes the certificate
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ing), ing)).Should(Succeed())
+			delete(ing.Annotations, "certs.k8c.io/issue")
+			Expect(k8sClient.Update(ctx, ing)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cert)
+			}, timeout, interval).ShouldNot(Succeed())
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
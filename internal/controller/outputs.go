@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/cert"
+)
+
+// keys of the additional output formats in the Secret data
+const (
+	keystoreP12   = "keystore.p12"
+	truststoreP12 = "truststore.p12"
+	keystoreJKS   = "keystore.jks"
+	truststoreJKS = "truststore.jks"
+	combinedPEM   = "tls-combined.pem"
+	certDER       = "tls.der"
+	keyDER        = "key.der"
+	encryptedKey  = "tls-encrypted.key"
+)
+
+// outputKeys lists every Secret data key written for additional output formats.
+var outputKeys = []string{keystoreP12, truststoreP12, keystoreJKS, truststoreJKS, combinedPEM, certDER, keyDER, encryptedKey}
+
+// outputsHash returns a digest of everything the additional output formats
+// of the credentials in data are rendered from. Keystores are salted, so the
+// digest rather than their content tells whether they are stale. Passwords are
+// represented by the resourceVersion of their Secret, so that the digest can be
+// checked without reading them, and without paying for the key derivation.
+func (rh *requestHandler) outputsHash(ctx context.Context,
+	obj *certsv1.Certificate, data map[string][]byte) (string, error) {
+
+	if len(obj.Spec.AdditionalOutputFormats) == 0 {
+		return "", nil
+	}
+
+	// the digest is keyed with the private key, so that it
+	// does not reveal anything about the credentials
+	keys := secretKeysOf(obj)
+	digest := hmac.New(sha256.New, data[keys.key])
+	digest.Write(data[keys.cert])
+	digest.Write(data[keys.ca])
+
+	for _, format := range obj.Spec.AdditionalOutputFormats {
+		fmt.Fprintf(digest, "\x00%s", format.Type)
+
+		if needsPassword(format.Type) {
+			version, err := rh.passwordVersion(ctx, obj.Namespace, format.PasswordSecretRef)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(digest, "\x00%s\x00%s\x00%s", format.PasswordSecretRef.Name, passwordKey(format.PasswordSecretRef), version)
+		}
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// renderOutputs returns the additional output formats of the credentials in data.
+func (rh *requestHandler) renderOutputs(ctx context.Context,
+	obj *certsv1.Certificate, data map[string][]byte) (map[string][]byte, error) {
+
+	keys := secretKeysOf(obj)
+	kp, err := cert.DecodeKeypair(data[keys.key], data[keys.cert])
+	if err != nil {
+		return nil, err
+	}
+
+	trusted, err := cert.DecodeAll(data[keys.ca])
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string][]byte{}
+	for _, format := range obj.Spec.AdditionalOutputFormats {
+		var password string
+		if needsPassword(format.Type) {
+			if password, err = rh.outputPassword(ctx, obj.Namespace, format.PasswordSecretRef); err != nil {
+				return nil, err
+			}
+		}
+
+		switch format.Type {
+		case certsv1.OutputFormatPKCS12:
+			if out[keystoreP12], err = kp.PKCS12(password); err != nil {
+				return nil, err
+			}
+			if out[truststoreP12], err = cert.PKCS12TrustStore(trusted, password); err != nil {
+				return nil, err
+			}
+		case certsv1.OutputFormatJKS:
+			if out[keystoreJKS], err = kp.JKS(password); err != nil {
+				return nil, err
+			}
+			if out[truststoreJKS], err = cert.JKSTrustStore(trusted, password); err != nil {
+				return nil, err
+			}
+		case certsv1.OutputFormatEncryptedPKCS8:
+			if out[encryptedKey], err = kp.EncryptedPKCS8(password); err != nil {
+				return nil, err
+			}
+		case certsv1.OutputFormatCombinedPEM:
+			out[combinedPEM] = append(append([]byte{}, data[keys.key]...), data[keys.cert]...)
+		case certsv1.OutputFormatDER:
+			out[certDER] = kp.Cert.Raw
+			if out[keyDER], err = kp.PKCS8(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.Errorf("unsupported output format %q", format.Type)
+		}
+	}
+
+	return out, nil
+}
+
+// needsPassword returns whether the output format is protected with a password.
+func needsPassword(format certsv1.OutputFormatType) bool {
+	switch format {
+	case certsv1.OutputFormatPKCS12, certsv1.OutputFormatJKS, certsv1.OutputFormatEncryptedPKCS8:
+		return true
+	default:
+		return false
+	}
+}
+
+// passwordKey returns the key of the password in the Secret referenced by ref.
+func passwordKey(ref *certsv1.SecretKeyRef) string {
+	if ref.Key == "" {
+		return defaultPasswordKey
+	}
+
+	return ref.Key
+}
+
+// passwordVersion returns the resourceVersion of the Secret referenced by ref.
+func (rh *requestHandler) passwordVersion(ctx context.Context, ns string, ref *certsv1.SecretKeyRef) (string, error) {
+	sec, err := rh.passwordSecret(ctx, ns, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return sec.ResourceVersion, nil
+}
+
+// passwordSecret returns the Secret referenced by ref from the cache, which
+// only holds password Secrets labelled certs.k8c.io/managed=password.
+func (rh *requestHandler) passwordSecret(ctx context.Context, ns string, ref *certsv1.SecretKeyRef) (*corev1.Secret, error) {
+	if ref == nil {
+		return nil, errors.New("no password secret given")
+	}
+
+	var sec corev1.Secret
+	if err := rh.client.Get(ctx, client.ObjectKey{Namespace: ns, Name: ref.Name}, &sec); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errors.Errorf("password secret %s not found, or not labelled %s=%s",
+				ref.Name, labelManaged, managedPassword)
+		}
+
+		return nil, errors.Wrapf(err, "unable to get password secret %s", ref.Name)
+	}
+
+	return &sec, nil
+}
+
+// outputPassword reads the password referenced by ref.
+func (rh *requestHandler) outputPassword(ctx context.Context, ns string, ref *certsv1.SecretKeyRef) (string, error) {
+	sec, err := rh.passwordSecret(ctx, ns, ref)
+	if err != nil {
+		return "", err
+	}
+
+	key := passwordKey(ref)
+	password, ok := sec.Data[key]
+	if !ok {
+		return "", errors.Errorf("password secret %s has no key %s", ref.Name, key)
+	}
+
+	return string(password), nil
+}
+
+// applyOutputs writes the additional output formats into the Secret, and
+// removes those no longer requested. Returns whether the data changed.
+// A failure is reported on the Certificate and leaves the Secret untouched.
+func (rh *requestHandler) applyOutputs(ctx context.Context, obj *certsv1.Certificate, sec *corev1.Secret) (bool, error) {
+	hash, err := rh.outputsHash(ctx, obj, sec.Data)
+	if err == nil && sec.Annotations[annotationOutputsHash] == hash {
+		return false, nil
+	}
+
+	var out map[string][]byte
+	if err == nil && hash != "" {
+		out, err = rh.renderOutputs(ctx, obj, sec.Data)
+	}
+	if err != nil {
+		rh.recorder.Eventf(obj, corev1.EventTypeWarning, reasonOutputFormatFailed,
+			"unable to write additional output formats into secret %s: %v", sec.Name, err)
+
+		return false, err
+	}
+
+	for _, k := range outputKeys {
+		delete(sec.Data, k)
+	}
+	for k, v := range out {
+		sec.Data[k] = v
+	}
+
+	if sec.Annotations == nil {
+		sec.Annotations = map[string]string{}
+	}
+	if hash == "" {
+		delete(sec.Annotations, annotationOutputsHash)
+	} else {
+		sec.Annotations[annotationOutputsHash] = hash
+	}
+
+	return true, nil
+}
+
+// syncSecretOutputs keeps the additional output formats of the Secret in
+// line with the credentials it holds. Returns true if the Secret is immutable
+// and can only be updated by reissuing its credentials.
+func (rh *requestHandler) syncSecretOutputs(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret) (bool, error) {
+
+	orig := sec.DeepCopy()
+	changed, err := rh.applyOutputs(ctx, obj, sec)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	if sec.Immutable != nil && *sec.Immutable {
+		return true, nil
+	}
+
+	rh.logger.Info("updating additional output formats", "name", client.ObjectKeyFromObject(sec).String())
+
+	return false, rh.client.Patch(ctx, sec, client.MergeFrom(orig))
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"software.sslmate.com/src/go-pkcs12"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+func TestApplyOutputsRendersOnlyChangedInputs(t *testing.T) {
+	ctx := context.Background()
+	caPEM, keyPEM, crtPEM := issueBackdated(t, 0, 24*time.Hour)
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = certsv1.AddToScheme(scheme)
+
+	password := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "keystore-password",
+			Namespace: "todo",
+			Labels:    map[string]string{labelManaged: managedPassword},
+		},
+		Data: map[string][]byte{defaultPasswordKey: []byte("changeit")},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(password).Build()
+	rh := newRequestHandler(logr.Discard(), c, c, record.NewFakeRecorder(10), nil)
+
+	obj := &certsv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "todo-app", Namespace: "todo"},
+		Spec: certsv1.CertificateSpec{
+			SecretRef: certsv1.SecretRef{Name: "todo-app"},
+			AdditionalOutputFormats: []certsv1.OutputFormat{{
+				Type:              certsv1.OutputFormatPKCS12,
+				PasswordSecretRef: &certsv1.SecretKeyRef{Name: password.Name},
+			}},
+		},
+	}
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "todo-app", Namespace: "todo"},
+		Data: map[string][]byte{
+			tlsKey:  keyPEM,
+			tlsCert: crtPEM,
+			caCert:  caPEM,
+		},
+	}
+
+	apply := func(want bool, password string) []byte {
+		t.Helper()
+
+		changed, err := rh.applyOutputs(ctx, obj, sec)
+		if err != nil {
+			t.Fatalf("unable to apply outputs: %v", err)
+		}
+		if changed != want {
+			t.Fatalf("expected changed to be %t", want)
+		}
+		if _, _, _, err := pkcs12.DecodeChain(sec.Data[keystoreP12], password); err != nil {
+			t.Fatalf("unable to decode keystore with password %q: %v", password, err)
+		}
+
+		return sec.Data[keystoreP12]
+	}
+
+	rendered := apply(true, "changeit")
+
+	// keystores are salted, so rendering again would change them
+	if again := apply(false, "changeit"); !bytes.Equal(again, rendered) {
+		t.Fatal("expected the keystore not to be rendered again")
+	}
+
+	password.Data[defaultPasswordKey] = []byte("rotated")
+	if err := c.Update(ctx, password); err != nil {
+		t.Fatalf("unable to rotate password: %v", err)
+	}
+	apply(true, "rotated")
+
+	// dropped formats are removed along with the digest
+	obj.Spec.AdditionalOutputFormats = nil
+	if changed, err := rh.applyOutputs(ctx, obj, sec); err != nil || !changed {
+		t.Fatalf("expected the outputs to be removed, changed: %t, err: %v", changed, err)
+	}
+	if _, ok := sec.Data[keystoreP12]; ok {
+		t.Fatal("expected the keystore to be removed")
+	}
+	if _, ok := sec.Annotations[annotationOutputsHash]; ok {
+		t.Fatal("expected the outputs hash to be removed")
+	}
+}
+
+func TestOutputPasswordNotCached(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	// unlabelled password Secrets are missing from the cache
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	rh := newRequestHandler(logr.Discard(), c, c, record.NewFakeRecorder(10), nil)
+
+	_, err := rh.outputPassword(context.Background(), "todo", &certsv1.SecretKeyRef{Name: "keystore-password"})
+	if err == nil || !strings.Contains(err.Error(), labelManaged+"="+managedPassword) {
+		t.Fatalf("expected the missing label to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+const (
+	// PodInjectorPath is the path the Pod webhook is served at.
+	PodInjectorPath = "/mutate-v1-pod"
+
+	// injectVolumeName is the name of the volume holding the Secret.
+	injectVolumeName = "certs-k8c-io-tls"
+)
+
+// the environment variables pointing at the mounted files
+const (
+	envCertFile = "TLS_CERT_FILE"
+	envKeyFile  = "TLS_KEY_FILE"
+	envCAFile   = "TLS_CA_FILE"
+)
+
+// PodInjector mounts the Secret of the Certificate named by the
+// certs.k8c.io/inject annotation into Pods, and rejects Pods whose
+// Certificate is not valid.
+type PodInjector struct {
+	Client  client.Reader
+	Decoder admission.Decoder
+
+	// MountPath is where the Secret is mounted, unless set on the Pod.
+	MountPath string
+}
+
+func (p *PodInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var pod corev1.Pod
+	if err := p.Decoder.Decode(req, &pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	name := pod.Annotations[annotationInject]
+	if name == "" {
+		return admission.Allowed("")
+	}
+
+	var crt certsv1.Certificate
+	if err := p.Client.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: name}, &crt); err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.Denied("certificate " + name + " does not exist")
+		}
+
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if crt.Status.State != certsv1.StateValid {
+		return admission.Denied("certificate " + name + " is not ready")
+	}
+
+	p.inject(&pod, &crt)
+
+	raw, err := json.Marshal(&pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// inject adds the Secret volume of the Certificate to the Pod, and mounts it
+// into the containers listed in certs.k8c.io/inject-containers, or all of them.
+// With certs.k8c.io/inject-env, the containers are told the paths of the files.
+func (p *PodInjector) inject(pod *corev1.Pod, crt *certsv1.Certificate) {
+	mountPath := p.MountPath
+	if v := pod.Annotations[annotationInjectMountPath]; v != "" {
+		mountPath = v
+	}
+
+	var selected []string
+	if v := pod.Annotations[annotationInjectContainers]; v != "" {
+		for _, name := range strings.Split(v, ",") {
+			selected = append(selected, strings.TrimSpace(name))
+		}
+	}
+
+	if !slices.ContainsFunc(pod.Spec.Volumes, func(v corev1.Volume) bool { return v.Name == injectVolumeName }) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: injectVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: crt.Spec.SecretRef.Name},
+			},
+		})
+	}
+
+	keys := secretKeysOf(crt)
+	env := []corev1.EnvVar{
+		{Name: envCertFile, Value: path.Join(mountPath, keys.cert)},
+		{Name: envKeyFile, Value: path.Join(mountPath, keys.key)},
+		{Name: envCAFile, Value: path.Join(mountPath, keys.ca)},
+	}
+
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if len(selected) > 0 && !slices.Contains(selected, c.Name) {
+			continue
+		}
+
+		if !slices.ContainsFunc(c.VolumeMounts, func(m corev1.VolumeMount) bool { return m.Name == injectVolumeName }) {
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+				Name:      injectVolumeName,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+		}
+
+		if pod.Annotations[annotationInjectEnv] != "true" {
+			continue
+		}
+
+		// variables set by the container itself win
+		for _, e := range env {
+			if !slices.ContainsFunc(c.Env, func(v corev1.EnvVar) bool { return v.Name == e.Name }) {
+				c.Env = append(c.Env, e)
+			}
+		}
+	}
+}
+
+// SetupWebhookWithManager registers the webhook with the webhook server.
+func (p *PodInjector) SetupWebhookWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(PodInjectorPath, &webhook.Admission{Handler: p})
+}
@@ -0,0 +1,130 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"encoding/json"
+
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	certsv1 "certificate-manager/api/v1"
+	"certificate-manager/internal/controller"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pod Injector", func() {
+	var (
+		ns       *corev1.Namespace
+		injector *controller.PodInjector
+	)
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+		}
+		Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+		injector = &controller.PodInjector{
+			Client:    k8sClient,
+			Decoder:   admission.NewDecoder(scheme.Scheme),
+			MountPath: "/etc/tls",
+		}
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+	})
+
+	// review sends a Pod annotated with the annotations through the webhook
+	review := func(annotations map[string]string) admission.Response {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "todo-app",
+				Namespace:   ns.Name,
+				Annotations: annotations,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "todo-app", Image: "todo-app:v0.1.0"},
+					{Name: "sidecar", Image: "sidecar"},
+				},
+			},
+		}
+		raw, err := json.Marshal(pod)
+		Expect(err).NotTo(HaveOccurred())
+
+		return injector.Handle(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: ns.Name,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}})
+	}
+
+	Context("When a pod names a certificate", func() {
+		It("Should reject the pod until the certificate is ready", func() {
+			resp := review(map[string]string{"certs.k8c.io/inject": certificateName})
+			Expect(resp.Allowed).Should(BeFalse())
+		})
+
+		It("Should mount the secret into the selected containers", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Eventually(func() certsv1.State {
+				_ = k8sClient.Get(ctx, key, cert)
+				return cert.Status.State
+			}, timeout, interval).Should(Equal(certsv1.StateValid))
+
+			resp := review(map[string]string{
+				"certs.k8c.io/inject":            certificateName,
+				"certs.k8c.io/inject-containers": "todo-app",
+				"certs.k8c.io/inject-env":        "true",
+			})
+			Expect(resp.Allowed).Should(BeTrue())
+
+			paths := make([]string, 0, len(resp.Patches))
+			for _, patch := range resp.Patches {
+				paths = append(paths, patch.Path)
+			}
+			Expect(paths).Should(ConsistOf(
+				"/spec/volumes",
+				"/spec/containers/0/volumeMounts",
+				"/spec/containers/0/env",
+			))
+		})
+	})
+
+	Context("When a pod does not name a certificate", func() {
+		It("Should leave the pod alone", func() {
+			resp := review(nil)
+			Expect(resp.Allowed).Should(BeTrue())
+			Expect(resp.Patches).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// releasePreviousSecret deletes or retains the Secret the credentials were
+// written to before spec.secretRef.name changed, as set by the deletion
+// policy, and records the current Secret in the status. It must only be
+// called once the current Secret holds valid credentials, so that
+// workloads can switch over without downtime.
+func (rh *requestHandler) releasePreviousSecret(ctx context.Context, obj *certsv1.Certificate) error {
+	prev, current := obj.Status.SecretName, obj.Spec.SecretRef.Name
+	if prev == "" || prev == current {
+		obj.Status.SecretName = current
+		return nil
+	}
+
+	owned, err := rh.ownedSecrets(ctx, obj)
+	if err != nil {
+		return err
+	}
+
+	// the previous Secret, and its revisions with the Immutable rotation strategy
+	var secrets []corev1.Secret
+	for _, sec := range owned {
+		if sec.Name == prev || sec.Labels[labelRevisionOf] == prev {
+			secrets = append(secrets, sec)
+		}
+	}
+
+	var (
+		names  []string
+		action string
+	)
+	switch obj.Spec.DeletionPolicy {
+	case certsv1.DeletionPolicyRetain:
+		names, err = rh.retainSecrets(ctx, obj, secrets)
+		action = "retained"
+	case certsv1.DeletionPolicyRevokeAndDelete:
+		names, err = rh.revokeSecrets(ctx, obj, secrets)
+		action = "revoked and deleted"
+	default:
+		names, err = rh.deleteSecrets(ctx, secrets)
+		action = "deleted"
+	}
+	if err != nil {
+		rh.recorder.Eventf(obj, corev1.EventTypeWarning, reasonDeletionFailed,
+			"unable to release previous secret %s: %v", prev, err)
+
+		return err
+	}
+
+	rh.recorder.Eventf(obj, corev1.EventTypeNormal, reasonSecretRenamed,
+		"credentials moved from secret %s to %s; %s previous secrets [%s]",
+		prev, current, action, strings.Join(names, ", "))
+
+	obj.Status.SecretName = current
+
+	return nil
+}
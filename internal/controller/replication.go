@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// syncReplicas keeps byte-identical copies of the Secret in the namespaces
+// selected for replication, deletes the copies in namespaces no longer
+// selected, and records the namespaces holding a copy in the status.
+// Returns whether the status changed.
+func (rh *requestHandler) syncReplicas(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret) (bool, error) {
+
+	targets, err := rh.replicaNamespaces(ctx, obj)
+	if err != nil {
+		return false, err
+	}
+
+	replicas, err := rh.listReplicas(ctx, obj)
+	if err != nil {
+		return false, err
+	}
+
+	current := make(map[string]*corev1.Secret, len(replicas))
+	var stale []corev1.Secret
+	for i := range replicas {
+		replica := &replicas[i]
+		if replica.Name == sec.Name && slices.Contains(targets, replica.Namespace) {
+			current[replica.Namespace] = replica
+			continue
+		}
+
+		// namespaces no longer selected, and copies of a renamed Secret
+		stale = append(stale, *replica)
+	}
+
+	synced := make([]string, 0, len(targets))
+	for _, ns := range targets {
+		ok, err := rh.upsertReplica(ctx, obj, sec, ns, current[ns])
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			synced = append(synced, ns)
+		}
+	}
+
+	if _, err := rh.deleteSecrets(ctx, stale); err != nil {
+		return false, err
+	}
+
+	sort.Strings(synced)
+	if slices.Equal(synced, obj.Status.ReplicaNamespaces) {
+		return false, nil
+	}
+
+	obj.Status.ReplicaNamespaces = synced
+
+	return true, nil
+}
+
+// replicaNamespaces returns the namespaces the Secret of the Certificate is
+// copied to; those matching the selector, that opted in to replication.
+func (rh *requestHandler) replicaNamespaces(ctx context.Context, obj *certsv1.Certificate) ([]string, error) {
+	if obj.Spec.SecretReplication == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&obj.Spec.SecretReplication.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := labels.NewRequirement(labelAllowReplication, selection.Equals, []string{"true"})
+	if err != nil {
+		return nil, err
+	}
+
+	var list corev1.NamespaceList
+	if err := rh.client.List(ctx, &list,
+		client.MatchingLabelsSelector{Selector: selector.Add(*allowed)},
+	); err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		if ns.Name != obj.Namespace && ns.DeletionTimestamp.IsZero() {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	return namespaces, nil
+}
+
+// listReplicas returns the copies of the Secret of the Certificate in all namespaces.
+func (rh *requestHandler) listReplicas(ctx context.Context, obj *certsv1.Certificate) ([]corev1.Secret, error) {
+	var list corev1.SecretList
+	if err := rh.client.List(ctx, &list,
+		client.MatchingLabels{labelManaged: "true", labelReplicaOf: string(obj.UID)},
+	); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// upsertReplica creates or updates the copy of the Secret in the namespace.
+// A Secret of the same name not created as a replica is never overwritten;
+// returns false in that case.
+func (rh *requestHandler) upsertReplica(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret, ns string, current *corev1.Secret) (bool, error) {
+
+	replica := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sec.Name,
+			Namespace: ns,
+			Labels:    map[string]string{labelManaged: "true", labelReplicaOf: string(obj.UID)},
+			Annotations: map[string]string{
+				annotationReplicaOf: obj.Namespace + "/" + obj.Name,
+				annotationRevision:  sec.Annotations[annotationRevision],
+			},
+		},
+		Type: sec.Type,
+		Data: make(map[string][]byte, len(sec.Data)),
+	}
+	for k, v := range sec.Data {
+		replica.Data[k] = v
+	}
+
+	if current == nil {
+		err := rh.client.Create(ctx, replica)
+		if apierrors.IsAlreadyExists(err) {
+			rh.recorder.Eventf(obj, corev1.EventTypeWarning, reasonReplicaConflict,
+				"secret %s already exists in namespace %s, and is not a replica", sec.Name, ns)
+
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	if current.Type == replica.Type && dataEqual(current.Data, replica.Data) {
+		return true, nil
+	}
+
+	return true, rh.upsertSecret(ctx, replica, current)
+}
+
+// dataEqual returns whether both Secrets hold the same data.
+func dataEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+
+	return true
+}
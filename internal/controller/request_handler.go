@@ -2,45 +2,52 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certsv1 "certificate-manager/api/v1"
 	"certificate-manager/internal/cert"
+	"certificate-manager/internal/metrics"
 )
 
 type requestHandler struct {
-	logger logr.Logger
-	client client.Client
-	ca     cert.CertAuthority
+	logger   logr.Logger
+	client   client.Client
+	reader   client.Reader
+	recorder record.EventRecorder
+	ca       cert.CertAuthority
 }
 
-func newRequestHandler(logger logr.Logger,
-	client client.Client, ca cert.CertAuthority) *requestHandler {
+func newRequestHandler(logger logr.Logger, client client.Client, reader client.Reader,
+	recorder record.EventRecorder, ca cert.CertAuthority) *requestHandler {
 
-	return &requestHandler{logger, client, ca}
+	return &requestHandler{logger, client, reader, recorder, ca}
 }
 
 func (rh requestHandler) updateStatusIfNeeded(
 	ctx context.Context, cert *certsv1.Certificate) (time.Duration, error) {
 
-	// if it's a new certificate or the credentials have expired
-	// then create a new secret with valid credentials
-	if cert.Status.State == "" || cert.Status.State == certsv1.StateExpired {
-		if err := rh.createSecret(ctx, cert); err != nil {
-			return reconcileInAMinute, err
-		}
+	if !cert.DeletionTimestamp.IsZero() {
+		return rh.finalize(ctx, cert)
+	}
 
-		cert.Status.State = certsv1.StateValid
-		if err := rh.client.Status().Update(ctx, cert); err != nil {
-			return reconcileShortly, err
-		}
+	if err := rh.syncFinalizer(ctx, cert); err != nil {
+		return reconcileShortly, err
+	}
 
-		return reconcileNone, nil
+	// if it's a new certificate, the credentials have expired or the
+	// secret was in conflict, then make sure there's a secret with valid credentials
+	switch cert.Status.State {
+	case "", certsv1.StateExpired, certsv1.StateConflict:
+		return rh.ensureSecret(ctx, cert)
 	}
 
 	// at this point we have a secret which may or maynot have valid credentials
@@ -55,6 +62,15 @@ func (rh requestHandler) updateStatusIfNeeded(
 		// if the secret is not found, set the state as Expired
 		// so that a new one can be created
 		if errors.IsNotFound(err) {
+			if cert.Status.SecretName != "" && cert.Status.SecretName != key.Name {
+				rh.recorder.Eventf(cert, corev1.EventTypeNormal, reasonSpecChanged,
+					"secret reference changed from %s to %s, issuing certificate", cert.Status.SecretName, key.Name)
+			} else {
+				rh.recorder.Eventf(cert, corev1.EventTypeWarning, reasonSecretMissing,
+					"secret %s not found, a new certificate will be issued", key.Name)
+			}
+			metrics.RecordReady(cert.Namespace, cert.Name, rh.issuerName(), false)
+
 			cert.Status.State = certsv1.StateExpired
 
 			return reconcileShortly, rh.client.Status().Update(ctx, cert)
@@ -65,9 +81,31 @@ func (rh requestHandler) updateStatusIfNeeded(
 		return reconcileShortly, err
 	}
 
+	// retry releasing the previous Secret after a rename, and record
+	// the Secret of Certificates that predate the status field
+	if cert.Status.SecretName != key.Name {
+		if err := rh.releasePreviousSecret(ctx, cert); err != nil {
+			return reconcileShortly, err
+		}
+
+		return reconcileShortly, rh.client.Status().Update(ctx, cert)
+	}
+
+	// credentials stored under other keys, or in a Secret of another type,
+	// are rewritten in place, or into a new Secret if the type changed
+	keys := secretKeysOf(cert)
+	if !keys.matches(&sec) {
+		rh.recorder.Eventf(cert, corev1.EventTypeNormal, reasonSpecChanged,
+			"secret %s does not hold the credentials under the expected keys, reissuing certificate", key.Name)
+
+		cert.Status.State = certsv1.StateExpired
+
+		return reconcileShortly, rh.client.Status().Update(ctx, cert)
+	}
+
 	// check if desired state has shifted from the state in external world
 	var extCert certsv1.Certificate
-	err = getCertFromExternalWorld(&sec, &extCert)
+	err = getCertFromExternalWorld(&sec, keys.cert, &extCert)
 	if err != nil {
 		rh.logger.Error(err, "unable to get certificate from external world", "name", key.String())
 
@@ -75,28 +113,127 @@ func (rh requestHandler) updateStatusIfNeeded(
 	}
 
 	if certificateHasChanges(cert, &extCert) {
+		rh.recorder.Eventf(cert, corev1.EventTypeNormal, reasonSpecChanged,
+			"certificate spec no longer matches secret %s, reissuing certificate", key.Name)
+		metrics.RecordReady(cert.Namespace, cert.Name, rh.issuerName(), false)
+
+		// the credentials are rotated in place once the state is Expired
 		cert.Status.State = certsv1.StateExpired
-		if err := rh.client.Delete(ctx, &sec); err != nil {
-			return reconcileShortly, err
-		}
 
 		return reconcileShortly, rh.client.Status().Update(ctx, cert)
 	}
 
 	// check if the certificate has expired
-	expired, err := rh.hasCertificateExpired(sec)
+	expired, err := rh.hasCertificateExpired(cert, sec)
 	if err != nil {
 		rh.logger.Error(err, "unable to validate secret credentials", "name", key.String())
 
 		return reconcileShortly, err
 	}
 
+	crt, err := getX509Certificate(sec.Data[keys.cert])
+	if err != nil {
+		rh.logger.Error(err, "unable to parse secret certificate", "name", key.String())
+
+		return reconcileShortly, err
+	}
+
+	metrics.RecordCertificate(cert.Namespace, cert.Name, crt, !expired)
+
 	if expired {
+		rh.recorder.Eventf(cert, corev1.EventTypeWarning, reasonExpired,
+			"certificate has expired (%s), a new certificate will be issued", describe(crt))
+
 		cert.Status.State = certsv1.StateExpired
 		rh.logger.Info("secret credentials have expired", "name", key.String())
 
 		return reconcileShortly, rh.client.Status().Update(ctx, cert)
 	}
 
-	return reconcileNone, nil
+	// credentials issued by a previous CA are not trusted by
+	// clients using the current ca.crt, so rotate them
+	caCrt, err := getX509Certificate(rh.ca.CACert())
+	if err != nil {
+		return reconcileShortly, err
+	}
+
+	if err := crt.CheckSignatureFrom(caCrt); err != nil {
+		rh.recorder.Eventf(cert, corev1.EventTypeNormal, reasonCAChanged,
+			"certificate was not issued by the current CA (%s), reissuing certificate", describe(crt))
+
+		cert.Status.State = certsv1.StateExpired
+
+		return reconcileShortly, rh.client.Status().Update(ctx, cert)
+	}
+
+	reissue, err := rh.syncSecretChain(ctx, cert, &sec, crt)
+	if err != nil {
+		rh.logger.Error(err, "unable to repair secret certificate chain", "name", key.String())
+
+		return reconcileShortly, err
+	}
+
+	if reissue {
+		cert.Status.State = certsv1.StateExpired
+
+		return reconcileShortly, rh.client.Status().Update(ctx, cert)
+	}
+
+	reissue, err = rh.syncSecretOutputs(ctx, cert, &sec)
+	if err != nil {
+		rh.logger.Error(err, "unable to update additional output formats", "name", key.String())
+
+		return reconcileInAMinute, nil
+	}
+
+	if reissue {
+		cert.Status.State = certsv1.StateExpired
+
+		return reconcileShortly, rh.client.Status().Update(ctx, cert)
+	}
+
+	if err := rh.syncSecretMetadata(ctx, cert, &sec, crt); err != nil {
+		rh.logger.Error(err, "unable to update secret metadata", "name", key.String())
+
+		return reconcileShortly, err
+	}
+
+	replicated, err := rh.syncReplicas(ctx, cert, &sec)
+	if err != nil {
+		rh.logger.Error(err, "unable to replicate secret", "name", key.String())
+
+		return reconcileShortly, err
+	}
+
+	if replicated {
+		if err := rh.client.Status().Update(ctx, cert); err != nil {
+			return reconcileShortly, err
+		}
+	}
+
+	// warn once the certificate has entered the last third of its lifetime,
+	// and come back when either the warning or the expiry is due; the
+	// condition keeps the warning from being repeated until renewal
+	warnAt := crt.NotAfter.Add(-crt.NotAfter.Sub(crt.NotBefore) / 3)
+	if time.Now().After(warnAt) {
+		if !meta.IsStatusConditionTrue(cert.Status.Conditions, certsv1.ConditionExpiringSoon) {
+			msg := fmt.Sprintf("certificate expires soon (%s)", describe(crt))
+			rh.recorder.Event(cert, corev1.EventTypeWarning, reasonExpiringSoon, msg)
+
+			meta.SetStatusCondition(&cert.Status.Conditions, metav1.Condition{
+				Type:               certsv1.ConditionExpiringSoon,
+				Status:             metav1.ConditionTrue,
+				Reason:             reasonExpiringSoon,
+				Message:            msg,
+				ObservedGeneration: cert.Generation,
+			})
+			if err := rh.client.Status().Update(ctx, cert); err != nil {
+				return reconcileShortly, err
+			}
+		}
+
+		return time.Until(crt.NotAfter), nil
+	}
+
+	return time.Until(warnAt), nil
 }
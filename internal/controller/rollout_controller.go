@@ -0,0 +1,328 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// annotationSecretRevisions holds the revisions of the managed Secrets
+	// used by the Pods of a workload, as name=revision pairs.
+	annotationSecretRevisions = "certs.k8c.io/secret-revisions"
+
+	// annotationRestartedFor is set on the Pod template of a restarted
+	// workload, to the renewed Secrets and their new revisions.
+	annotationRestartedFor = "certs.k8c.io/restarted-for"
+
+	// workloadSecretsField indexes workloads by the Secrets they use.
+	workloadSecretsField = ".spec.template.secrets"
+)
+
+// The kinds of workloads restarted once a Secret they use is renewed.
+var (
+	DeploymentKind  = appsv1.SchemeGroupVersion.WithKind("Deployment")
+	StatefulSetKind = appsv1.SchemeGroupVersion.WithKind("StatefulSet")
+	DaemonSetKind   = appsv1.SchemeGroupVersion.WithKind("DaemonSet")
+
+	// WorkloadKinds lists all kinds of workloads restarted on renewal.
+	WorkloadKinds = []schema.GroupVersionKind{
+		DeploymentKind,
+		StatefulSetKind,
+		DaemonSetKind,
+	}
+)
+
+// RolloutReconciler restarts the workloads of one kind whose Pods use a
+// managed Secret, through a volume, environment variables or the
+// certs.k8c.io/restart-secrets annotation, once its credentials are renewed.
+// The revisions the Pods were started with are recorded on the workload;
+// a workload seen for the first time is assumed to be up to date.
+type RolloutReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Kind is one of WorkloadKinds.
+	Kind schema.GroupVersionKind
+
+	// Limiter throttles the restarts. It is shared by the reconcilers of
+	// all kinds, so that a wave of renewals is rolled out gradually.
+	Limiter *rate.Limiter
+}
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;patch
+
+func (r *RolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+
+	obj, err := r.newObject()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !obj.GetDeletionTimestamp().IsZero() || obj.GetAnnotations()[annotationRestartOnRenewal] == "false" {
+		return reconcile.Result{}, nil
+	}
+
+	tmpl := podTemplateOf(obj)
+	if tmpl == nil {
+		return reconcile.Result{}, errors.Errorf("unsupported kind %s", r.Kind)
+	}
+
+	current, err := r.secretRevisions(ctx, obj.GetNamespace(), workloadSecrets(obj))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Secrets seen for the first time are assumed to be mounted already
+	recorded := parseRevisions(obj.GetAnnotations()[annotationSecretRevisions])
+	var renewed []string
+	for name, revision := range current {
+		if previous, ok := recorded[name]; ok && previous != revision {
+			renewed = append(renewed, name+"="+revision)
+		}
+	}
+	sort.Strings(renewed)
+
+	revisions := formatRevisions(current)
+	if len(renewed) == 0 && obj.GetAnnotations()[annotationSecretRevisions] == revisions {
+		return reconcile.Result{}, nil
+	}
+
+	if len(renewed) > 0 {
+		reservation := r.Limiter.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			logger.V(1).Info("rollout restart throttled", "kind", r.Kind.Kind, "name", obj.GetName(), "delay", delay)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[annotationSecretRevisions] = revisions
+	obj.SetAnnotations(annotations)
+
+	if len(renewed) > 0 {
+		if tmpl.Annotations == nil {
+			tmpl.Annotations = map[string]string{}
+		}
+		tmpl.Annotations[annotationRestartedFor] = strings.Join(renewed, ",")
+	}
+
+	if err := r.Patch(ctx, obj, patch); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if len(renewed) > 0 {
+		logger.Info("restarting workload", "kind", r.Kind.Kind, "name", obj.GetName(), "secrets", renewed)
+		r.Recorder.Eventf(obj, corev1.EventTypeNormal, reasonRolloutRestarted,
+			"restarting for renewed secrets %s", strings.Join(renewed, ", "))
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// secretRevisions returns the revisions of the named Secrets. Only managed
+// Secrets are cached, so any other Secret is left out, as are Secrets
+// without credentials yet.
+func (r *RolloutReconciler) secretRevisions(ctx context.Context, ns string, names []string) (map[string]string, error) {
+	revisions := make(map[string]string, len(names))
+	for _, name := range names {
+		var sec corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &sec); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if revision := sec.Annotations[annotationRevision]; revision != "" {
+			revisions[name] = revision
+		}
+	}
+
+	return revisions, nil
+}
+
+// parseRevisions parses the name=revision pairs recorded on a workload.
+func parseRevisions(v string) map[string]string {
+	revisions := map[string]string{}
+	for _, pair := range splitNames(v) {
+		if name, revision, ok := strings.Cut(pair, "="); ok {
+			revisions[name] = revision
+		}
+	}
+
+	return revisions
+}
+
+// formatRevisions returns the revisions as name=revision pairs, by name.
+func formatRevisions(revisions map[string]string) string {
+	pairs := make([]string, 0, len(revisions))
+	for name, revision := range revisions {
+		pairs = append(pairs, name+"="+revision)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// podTemplateOf returns the Pod template of the workload, or nil for
+// objects of any other kind.
+func podTemplateOf(obj client.Object) *corev1.PodTemplateSpec {
+	switch w := obj.(type) {
+	case *appsv1.Deployment:
+		return &w.Spec.Template
+	case *appsv1.StatefulSet:
+		return &w.Spec.Template
+	case *appsv1.DaemonSet:
+		return &w.Spec.Template
+	default:
+		return nil
+	}
+}
+
+// workloadSecrets returns the names of the Secrets used by the Pods of the
+// workload; those mounted as volumes, those the environment is read from,
+// and those listed in certs.k8c.io/restart-secrets.
+func workloadSecrets(obj client.Object) []string {
+	names := splitNames(obj.GetAnnotations()[annotationRestartSecrets])
+
+	tmpl := podTemplateOf(obj)
+	if tmpl == nil {
+		return names
+	}
+
+	for _, vol := range tmpl.Spec.Volumes {
+		if vol.Secret != nil {
+			names = append(names, vol.Secret.SecretName)
+		}
+
+		if vol.Projected != nil {
+			for _, source := range vol.Projected.Sources {
+				if source.Secret != nil {
+					names = append(names, source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	containers := slices.Concat(tmpl.Spec.InitContainers, tmpl.Spec.Containers)
+	for _, c := range containers {
+		for _, from := range c.EnvFrom {
+			if from.SecretRef != nil {
+				names = append(names, from.SecretRef.Name)
+			}
+		}
+
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names = append(names, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+
+	return slices.DeleteFunc(slices.Compact(names), func(name string) bool { return name == "" })
+}
+
+// splitNames splits a comma separated list, dropping empty items.
+func splitNames(v string) []string {
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (r *RolloutReconciler) newObject() (client.Object, error) {
+	obj, err := r.Scheme.New(r.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.(client.Object), nil
+}
+
+// workloadsForSecret maps a Secret to the workloads using it.
+func (r *RolloutReconciler) workloadsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	list, err := r.Scheme.New(r.Kind.GroupVersion().WithKind(r.Kind.Kind + "List"))
+	if err != nil {
+		return nil
+	}
+
+	if err := r.List(ctx, list.(client.ObjectList),
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{workloadSecretsField: obj.GetName()},
+	); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	_ = meta.EachListItem(list, func(item runtime.Object) error {
+		if w, ok := item.(client.Object); ok {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: w.GetNamespace(), Name: w.GetName()},
+			})
+		}
+
+		return nil
+	})
+
+	return requests
+}
+
+// SetupWithManager sets up the reconciler for its kind.
+func (r *RolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	obj, err := r.newObject()
+	if err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, workloadSecretsField,
+		workloadSecrets); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("rollout-"+strings.ToLower(r.Kind.Kind)).
+		For(obj).
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.workloadsForSecret),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{}),
+		).
+		Complete(r)
+}
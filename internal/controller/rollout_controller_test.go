@@ -0,0 +1,92 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"go.uber.org/mock/gomock"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollout Controller", func() {
+	Context("When a deployment mounts the secret of a certificate", func() {
+		It("Should restart the deployment once the certificate is renewed", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			cert := &certsv1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      certificateName,
+					Namespace: ns.Name,
+				},
+				Spec: certsv1.CertificateSpec{
+					Organization: "k8c",
+					DNSName:      "test.k8c.io",
+					AltNames:     []string{"localhost"},
+					SecretRef: certsv1.SecretRef{
+						Name: secretName,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cert)).Should(Succeed())
+
+			labels := map[string]string{"app": "todo-app"}
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "todo-app",
+					Namespace: ns.Name,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "todo-app", Image: "todo-app:v0.1.0"}},
+							Volumes: []corev1.Volume{{
+								Name: "certs",
+								VolumeSource: corev1.VolumeSource{
+									Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+								},
+							}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deploy)).Should(Succeed())
+
+			// the first revision is recorded without a restart
+			Eventually(func() map[string]string {
+				_ = k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)
+				return deploy.Annotations
+			}, timeout, interval).Should(HaveKeyWithValue("certs.k8c.io/secret-revisions", secretName+"=1"))
+			Expect(deploy.Spec.Template.Annotations).ShouldNot(HaveKey("certs.k8c.io/restarted-for"))
+
+			// the issued certificate no longer matches the spec
+			key := types.NamespacedName{Name: certificateName, Namespace: ns.Name}
+			Expect(k8sClient.Get(ctx, key, cert)).Should(Succeed())
+			cert.Spec.Organization = "k8c-rotated"
+			Expect(k8sClient.Update(ctx, cert)).Should(Succeed())
+
+			Eventually(func() map[string]string {
+				_ = k8sClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy)
+				return deploy.Spec.Template.Annotations
+			}, timeout, interval).Should(HaveKeyWithValue("certs.k8c.io/restarted-for", secretName+"=2"))
+			Expect(deploy.Annotations).Should(HaveKeyWithValue("certs.k8c.io/secret-revisions", secretName+"=2"))
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
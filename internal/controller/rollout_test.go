@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestRolloutRestartThrottled(t *testing.T) {
+	ctx := context.Background()
+	interval := 500 * time.Millisecond
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	// two workloads, each using a Secret renewed since they were started
+	var objs []client.Object
+	for _, name := range []string{"todo-app", "todo-api"} {
+		objs = append(objs,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Namespace:   "todo",
+					Annotations: map[string]string{annotationRevision: "2"},
+				},
+			},
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Namespace:   "todo",
+					Annotations: map[string]string{annotationSecretRevisions: name + "=1"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Volumes: []corev1.Volume{{
+								Name: "certs",
+								VolumeSource: corev1.VolumeSource{
+									Secret: &corev1.SecretVolumeSource{SecretName: name},
+								},
+							}},
+						},
+					},
+				},
+			},
+		)
+	}
+
+	patched := map[string]time.Time{}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				patched[obj.GetName()] = time.Now()
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	r := &RolloutReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+		Kind:     DeploymentKind,
+		Limiter:  rate.NewLimiter(rate.Every(interval), 1),
+	}
+
+	reconcile := func(name string) ctrl.Result {
+		t.Helper()
+
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "todo", Name: name}})
+		if err != nil {
+			t.Fatalf("unable to reconcile %s: %v", name, err)
+		}
+
+		return result
+	}
+
+	if result := reconcile("todo-app"); result.RequeueAfter != 0 {
+		t.Fatalf("expected the first workload to be restarted right away, requeued after %s", result.RequeueAfter)
+	}
+
+	result := reconcile("todo-api")
+	if result.RequeueAfter <= 0 || result.RequeueAfter > interval {
+		t.Fatalf("expected the second workload to wait up to %s, requeued after %s", interval, result.RequeueAfter)
+	}
+	if _, ok := patched["todo-api"]; ok {
+		t.Fatal("expected the second workload not to be patched while throttled")
+	}
+
+	time.Sleep(result.RequeueAfter)
+	if result := reconcile("todo-api"); result.RequeueAfter != 0 {
+		t.Fatalf("expected the second workload to be restarted once its turn came, requeued after %s", result.RequeueAfter)
+	}
+
+	if delay := patched["todo-api"].Sub(patched["todo-app"]); delay < interval*9/10 {
+		t.Fatalf("expected the second restart to be delayed by %s, got %s", interval, delay)
+	}
+
+	for _, name := range []string{"todo-app", "todo-api"} {
+		var deploy appsv1.Deployment
+		if err := c.Get(ctx, client.ObjectKey{Namespace: "todo", Name: name}, &deploy); err != nil {
+			t.Fatalf("unable to get %s: %v", name, err)
+		}
+		if got := deploy.Spec.Template.Annotations[annotationRestartedFor]; got != name+"=2" {
+			t.Fatalf("expected %s to be restarted for %s=2, got %q", name, name, got)
+		}
+		if got := deploy.Annotations[annotationSecretRevisions]; got != name+"=2" {
+			t.Fatalf("expected the new revision to be recorded on %s, got %q", name, got)
+		}
+	}
+}
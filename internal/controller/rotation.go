@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// writeSecret writes the Secret holding the credentials of the given revision.
+// An existing Secret is updated in place, so that its data is swapped atomically
+// and mounted volumes never see it missing. With the Immutable rotation strategy,
+// the revision is written to its own immutable Secret first, and the Secret is
+// kept as a stable alias of it.
+func (rh *requestHandler) writeSecret(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret, current *corev1.Secret, revision int64) error {
+
+	sec.Annotations[annotationRevision] = strconv.FormatInt(revision, 10)
+
+	immutable := obj.Spec.RotationStrategy == certsv1.RotationStrategyImmutable
+	if immutable {
+		rev := sec.DeepCopy()
+		rev.Name = revisionSecretName(sec.Name, revision)
+		rev.Labels[labelRevisionOf] = sec.Name
+		rev.Immutable = &isImmutable
+
+		// a leftover of a failed attempt may hold other credentials
+		err := rh.client.Create(ctx, rev)
+		if apierrors.IsAlreadyExists(err) {
+			if err = rh.client.Delete(ctx, rev); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+
+			err = rh.client.Create(ctx, rev)
+		}
+		if err != nil {
+			return err
+		}
+
+		sec.Annotations[annotationRevisionSecret] = rev.Name
+	}
+
+	if err := rh.upsertSecret(ctx, sec, current); err != nil {
+		return err
+	}
+
+	if immutable {
+		return rh.pruneRevisions(ctx, sec, revision)
+	}
+
+	return nil
+}
+
+// upsertSecret creates the Secret, or updates current in place. Secrets that
+// cannot be updated in place, such as immutable ones, are deleted and recreated.
+func (rh *requestHandler) upsertSecret(ctx context.Context, sec *corev1.Secret, current *corev1.Secret) error {
+	if current == nil {
+		return rh.client.Create(ctx, sec)
+	}
+
+	if (current.Immutable != nil && *current.Immutable) || current.Type != sec.Type {
+		if err := rh.client.Delete(ctx, current, client.Preconditions{UID: &current.UID}); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+
+		return rh.client.Create(ctx, sec)
+	}
+
+	sec.ResourceVersion = current.ResourceVersion
+
+	return rh.client.Update(ctx, sec)
+}
+
+// pruneRevisions deletes the revision Secrets of the alias, other than the
+// given revision and the revisionHistoryLimit revisions preceding it.
+func (rh *requestHandler) pruneRevisions(ctx context.Context, alias *corev1.Secret, revision int64) error {
+	var revisions corev1.SecretList
+	if err := rh.client.List(ctx, &revisions,
+		client.InNamespace(alias.Namespace),
+		client.MatchingLabels{labelManaged: "true", labelRevisionOf: alias.Name},
+	); err != nil {
+		return err
+	}
+
+	for i := range revisions.Items {
+		rev := &revisions.Items[i]
+		if secretRevision(rev) >= revision-revisionHistoryLimit {
+			continue
+		}
+
+		if err := rh.client.Delete(ctx, rev); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nextRevision returns the revision following both the one recorded in the
+// status and the one held by the current Secret, if any.
+func nextRevision(obj *certsv1.Certificate, current *corev1.Secret) int64 {
+	revision := obj.Status.Revision
+	if current != nil && secretRevision(current) > revision {
+		revision = secretRevision(current)
+	}
+
+	return revision + 1
+}
+
+// secretRevision returns the revision annotated on the Secret, or 0.
+func secretRevision(sec *corev1.Secret) int64 {
+	revision, err := strconv.ParseInt(sec.Annotations[annotationRevision], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return revision
+}
+
+func revisionSecretName(name string, revision int64) string {
+	return fmt.Sprintf("%s-%d", name, revision)
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+func TestWriteSecretKeepsPreviousRevision(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	rh := newRequestHandler(logr.Discard(), c, c, record.NewFakeRecorder(10), nil)
+
+	obj := &certsv1.Certificate{
+		Spec: certsv1.CertificateSpec{
+			SecretRef:        certsv1.SecretRef{Name: "todo-app"},
+			RotationStrategy: certsv1.RotationStrategyImmutable,
+		},
+	}
+
+	// issue, then rotate twice
+	var current *corev1.Secret
+	for revision := int64(1); revision <= 3; revision++ {
+		sec := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "todo-app",
+				Namespace:   "todo",
+				Labels:      map[string]string{labelManaged: "true"},
+				Annotations: map[string]string{},
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+		if err := rh.writeSecret(ctx, obj, sec, current, revision); err != nil {
+			t.Fatalf("unable to write revision %d: %v", revision, err)
+		}
+
+		current = &corev1.Secret{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(sec), current); err != nil {
+			t.Fatalf("unable to get alias: %v", err)
+		}
+	}
+
+	var revisions corev1.SecretList
+	if err := c.List(ctx, &revisions, client.MatchingLabels{labelRevisionOf: "todo-app"}); err != nil {
+		t.Fatalf("unable to list revisions: %v", err)
+	}
+
+	var names []string
+	for _, rev := range revisions.Items {
+		names = append(names, rev.Name)
+	}
+	slices.Sort(names)
+
+	if !slices.Equal(names, []string{"todo-app-2", "todo-app-3"}) {
+		t.Fatalf("expected the current and the previous revision to remain, got %v", names)
+	}
+
+	if current.Annotations[annotationRevisionSecret] != "todo-app-3" {
+		t.Fatalf("expected the alias to name revision 3, got %q", current.Annotations[annotationRevisionSecret])
+	}
+}
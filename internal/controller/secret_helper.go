@@ -1,13 +1,15 @@
 package controller
 
 import (
+	"cmp"
 	"context"
 	"crypto/x509"
-	"encoding/pem"
+	"math"
+	"net"
+	"slices"
 	"sort"
 	"time"
 
-	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -15,6 +17,7 @@ import (
 
 	certsv1 "certificate-manager/api/v1"
 	"certificate-manager/internal/cert"
+	"certificate-manager/internal/metrics"
 )
 
 func (rh *requestHandler) getSecret(ctx context.Context, key types.NamespacedName, obj *corev1.Secret) error {
@@ -26,41 +29,82 @@ func (rh *requestHandler) getSecret(ctx context.Context, key types.NamespacedNam
 	return nil
 }
 
-func (rh *requestHandler) createSecret(ctx context.Context, obj *certsv1.Certificate) error {
+// newSecret issues a new certificate and returns the Secret referenced by
+// the Certificate holding it, with the metadata of the secret template.
+// The labels and annotations of keep, if given, are carried over.
+// Returns the PEM encoded certificate as well.
+// Additional output formats that cannot be written are left out, to be
+// added once the problem is solved.
+func (rh *requestHandler) newSecret(ctx context.Context,
+	obj *certsv1.Certificate, keep *corev1.Secret) (*corev1.Secret, []byte, error) {
+	start := time.Now()
 	key, crt, err := rh.ca.IssueCert(cert.Request{
 		Organization: obj.Spec.Organization,
 		DNSName:      obj.Spec.DNSName,
 		ValidForDays: obj.Spec.ValidForDays,
 		AltNames:     obj.Spec.AltNames,
+		IPAddresses:  obj.Spec.IPAddresses,
+		URIs:         obj.Spec.URIs,
+		ExtKeyUsages: extKeyUsages(obj.Spec.Usages),
 	})
+	metrics.ObserveIssue(obj.Status.State != "", time.Since(start), err)
 	if err != nil {
-		return err
+		rh.recordIssueFailure(obj, err)
+
+		return nil, nil, err
 	}
 
+	parsed, err := getX509Certificate(crt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := secretKeysOf(obj)
 	sec := &corev1.Secret{
 		ObjectMeta: v1.ObjectMeta{
-			Name:        obj.Spec.SecretRef.Name,
-			Namespace:   obj.ObjectMeta.Namespace,
-			Labels:      obj.Labels,
-			Annotations: obj.Annotations,
+			Name:      obj.Spec.SecretRef.Name,
+			Namespace: obj.ObjectMeta.Namespace,
 		},
-		Immutable: &isImmutable,
-		Type:      corev1.SecretTypeTLS,
+		Type: keys.secretType(),
 		Data: map[string][]byte{
-			corev1.TLSPrivateKeyKey: key,
-			corev1.TLSCertKey:       crt,
+			keys.key:  key,
+			keys.cert: chainPEM(obj, parsed, rh.ca.CACert()),
+			keys.ca:   rh.ca.CACert(),
 		},
 	}
+	if keys.chain != "" {
+		sec.Data[keys.chain] = fullChainPEM(parsed, rh.ca.CACert())
+	}
+
+	if keep != nil {
+		sec.Labels = copyMap(keep.Labels)
+		sec.Annotations = copyMap(keep.Annotations)
+	}
+	applySecretMetadata(&sec.ObjectMeta, obj, parsed)
+
+	if _, err := rh.applyOutputs(ctx, obj, sec); err != nil {
+		rh.logger.Error(err, "unable to write additional output formats", "name", sec.Name)
+	}
 
 	if err := controllerutil.SetControllerReference(obj, sec, rh.client.Scheme()); err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	return sec, crt, nil
+}
+
+// issuerName returns the name of the issuing CA as used for metric labels.
+func (rh *requestHandler) issuerName() string {
+	crt, err := cert.Decode(rh.ca.CACert())
+	if err != nil {
+		return ""
 	}
 
-	return rh.client.Create(ctx, sec)
+	return metrics.IssuerName(crt.Subject)
 }
 
-func (rh *requestHandler) hasCertificateExpired(sec corev1.Secret) (bool, error) {
-	if data, ok := sec.Data[tlsCert]; ok {
+func (rh *requestHandler) hasCertificateExpired(obj *certsv1.Certificate, sec corev1.Secret) (bool, error) {
+	if data, ok := sec.Data[secretKeysOf(obj).cert]; ok {
 		return rh.ca.HasCertificateExpired(data)
 	}
 
@@ -68,6 +112,10 @@ func (rh *requestHandler) hasCertificateExpired(sec corev1.Secret) (bool, error)
 }
 
 func certificateHasChanges(n *certsv1.Certificate, o *certsv1.Certificate) bool {
+	if len(n.Spec.AltNames) != len(o.Spec.AltNames) {
+		return true
+	}
+
 	sort.Strings(n.Spec.AltNames)
 	sort.Strings(o.Spec.AltNames)
 
@@ -77,45 +125,109 @@ func certificateHasChanges(n *certsv1.Certificate, o *certsv1.Certificate) bool
 		}
 	}
 
-	return n.Spec.DNSName != o.Spec.DNSName ||
+	return ipAddressesChanged(n.Spec.IPAddresses, o.Spec.IPAddresses) ||
+		setChanged(n.Spec.URIs, o.Spec.URIs) ||
+		setChanged(n.Spec.Usages, o.Spec.Usages) ||
+		n.Spec.DNSName != o.Spec.DNSName ||
 		n.Spec.Organization != o.Spec.Organization ||
 		n.Spec.ValidForDays != o.Spec.ValidForDays ||
 		n.Spec.SecretRef.Name != o.Spec.SecretRef.Name
 }
 
-func getCertFromExternalWorld(obj *corev1.Secret, cert *certsv1.Certificate) error {
-	crt, err := getX509Certificate(obj.Data[tlsCert])
+// ipAddressesChanged returns whether the IP addresses of a certificate, have,
+// differ from those wanted. The loopback address every certificate holds is
+// left out.
+func ipAddressesChanged(want, have []string) bool {
+	contains := func(ips []string, ip net.IP) bool {
+		return slices.ContainsFunc(ips, func(s string) bool { return ip.Equal(net.ParseIP(s)) })
+	}
+
+	for _, s := range want {
+		if !contains(have, net.ParseIP(s)) {
+			return true
+		}
+	}
+	for _, s := range have {
+		if ip := net.ParseIP(s); !ip.IsLoopback() && !contains(want, ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setChanged returns whether two lists differ, regardless of their order.
+func setChanged[T cmp.Ordered](want, have []T) bool {
+	want, have = slices.Clone(want), slices.Clone(have)
+	slices.Sort(want)
+	slices.Sort(have)
+
+	return !slices.Equal(slices.Compact(want), slices.Compact(have))
+}
+
+// extKeyUsages maps the usages of a Certificate to extended key usages.
+func extKeyUsages(usages []certsv1.Usage) []x509.ExtKeyUsage {
+	var ekus []x509.ExtKeyUsage
+	for _, usage := range usages {
+		switch usage {
+		case certsv1.UsageServerAuth:
+			ekus = append(ekus, x509.ExtKeyUsageServerAuth)
+		case certsv1.UsageClientAuth:
+			ekus = append(ekus, x509.ExtKeyUsageClientAuth)
+		}
+	}
+
+	return ekus
+}
+
+// usagesOf maps the extended key usages of a certificate to the usages of
+// a Certificate. Unknown usages are left out.
+func usagesOf(crt *x509.Certificate) []certsv1.Usage {
+	var usages []certsv1.Usage
+	for _, eku := range crt.ExtKeyUsage {
+		switch eku {
+		case x509.ExtKeyUsageServerAuth:
+			usages = append(usages, certsv1.UsageServerAuth)
+		case x509.ExtKeyUsageClientAuth:
+			usages = append(usages, certsv1.UsageClientAuth)
+		}
+	}
+
+	return usages
+}
+
+func getCertFromExternalWorld(obj *corev1.Secret, crtKey string, cert *certsv1.Certificate) error {
+	crt, err := getX509Certificate(obj.Data[crtKey])
 	if err != nil {
 		return err
 	}
 
 	cert.Spec.DNSName = crt.Subject.CommonName
-	cert.Spec.Organization = crt.Subject.Organization[0]
+	if len(crt.Subject.Organization) > 0 {
+		cert.Spec.Organization = crt.Subject.Organization[0]
+	}
 	cert.Spec.AltNames = crt.DNSNames
-	cert.Spec.ValidForDays = getValidForDays(crt.NotAfter)
+	cert.Spec.IPAddresses = nil
+	for _, ip := range crt.IPAddresses {
+		cert.Spec.IPAddresses = append(cert.Spec.IPAddresses, ip.String())
+	}
+	cert.Spec.URIs = nil
+	for _, uri := range crt.URIs {
+		cert.Spec.URIs = append(cert.Spec.URIs, uri.String())
+	}
+	cert.Spec.Usages = usagesOf(crt)
+	cert.Spec.ValidForDays = getValidForDays(crt)
 	cert.Spec.SecretRef.Name = obj.ObjectMeta.Name
 
 	return nil
 }
 
 func getX509Certificate(crtBytes []byte) (*x509.Certificate, error) {
-	block, _ := pem.Decode(crtBytes)
-	crt, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "error decoding DER certificate bytes")
-	}
-
-	return crt, nil
+	return cert.Decode(crtBytes)
 }
 
-func getValidForDays(notAfter time.Time) int {
-	current := time.Now().Truncate(24 * time.Hour)
-	notAfter = notAfter.Truncate(24 * time.Hour)
-
-	if notAfter.After(current) {
-		duration := notAfter.Sub(current)
-		return int(duration.Hours() / 24)
-	}
-
-	return -1
+// getValidForDays returns the lifetime of a certificate in days, which is what
+// spec.validForDays asks for, however much of it has passed.
+func getValidForDays(crt *x509.Certificate) int {
+	return int(math.Round(crt.NotAfter.Sub(crt.NotBefore).Hours() / 24))
 }
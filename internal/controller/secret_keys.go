@@ -0,0 +1,59 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// secretKeys holds the names of the keys the credentials of a
+// Certificate are stored under in its Secret.
+type secretKeys struct {
+	key   string
+	cert  string
+	ca    string
+	chain string
+}
+
+// secretKeysOf returns the Secret keys of the Certificate, falling back
+// to the defaults for those not set.
+func secretKeysOf(obj *certsv1.Certificate) secretKeys {
+	keys := secretKeys{key: tlsKey, cert: tlsCert, ca: caCert}
+
+	spec := obj.Spec.SecretKeys
+	if spec == nil {
+		return keys
+	}
+
+	if spec.PrivateKey != "" {
+		keys.key = spec.PrivateKey
+	}
+	if spec.Certificate != "" {
+		keys.cert = spec.Certificate
+	}
+	if spec.CA != "" {
+		keys.ca = spec.CA
+	}
+	keys.chain = spec.Chain
+
+	return keys
+}
+
+// secretType returns the type of the Secret; a TLS Secret requires the
+// private key and certificate to be stored under tls.key and tls.crt.
+func (k secretKeys) secretType() corev1.SecretType {
+	if k.key == corev1.TLSPrivateKeyKey && k.cert == corev1.TLSCertKey {
+		return corev1.SecretTypeTLS
+	}
+
+	return corev1.SecretTypeOpaque
+}
+
+// matches returns whether the Secret is of the right type, and holds
+// the private key and certificate under the expected keys.
+func (k secretKeys) matches(sec *corev1.Secret) bool {
+	_, hasKey := sec.Data[k.key]
+	_, hasCert := sec.Data[k.cert]
+
+	return sec.Type == k.secretType() && hasKey && hasCert
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// syncSecretMetadata reconciles the labels and annotations of the Secret
+// holding crt with the secret template of the Certificate.
+func (rh *requestHandler) syncSecretMetadata(ctx context.Context,
+	obj *certsv1.Certificate, sec *corev1.Secret, crt *x509.Certificate) error {
+
+	patch := client.MergeFrom(sec.DeepCopy())
+	if !applySecretMetadata(&sec.ObjectMeta, obj, crt) {
+		return nil
+	}
+
+	return rh.client.Patch(ctx, sec, patch)
+}
+
+// applySecretMetadata sets the labels and annotations of the secret template,
+// and the controller's own, on the Secret holding crt. Template keys applied
+// before, but no longer in the template, are removed; all other keys are left
+// untouched. Returns whether the metadata changed.
+func applySecretMetadata(meta *v1.ObjectMeta, obj *certsv1.Certificate, crt *x509.Certificate) bool {
+	tmpl := obj.Spec.SecretTemplate
+	if tmpl == nil {
+		tmpl = &certsv1.SecretTemplate{}
+	}
+
+	labels := copyMap(tmpl.Labels)
+	labels[labelManaged] = "true"
+
+	annotations := copyMap(tmpl.Annotations)
+	annotations[annotationIssuer] = crt.Issuer.String()
+	annotations[annotationSerial] = crt.SerialNumber.Text(16)
+	annotations[annotationNotAfter] = crt.NotAfter.UTC().Format(time.RFC3339)
+	annotations[annotationSpecHash] = specHash(obj)
+	setTemplateKeys(annotations, annotationTemplateLabels, tmpl.Labels)
+	setTemplateKeys(annotations, annotationTemplateAnnotations, tmpl.Annotations)
+
+	changed := false
+	if meta.Labels == nil {
+		meta.Labels = map[string]string{}
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+
+	for _, k := range templateKeys(meta.Annotations[annotationTemplateLabels]) {
+		if _, ok := labels[k]; !ok {
+			delete(meta.Labels, k)
+			changed = true
+		}
+	}
+	for _, k := range templateKeys(meta.Annotations[annotationTemplateAnnotations]) {
+		if _, ok := annotations[k]; !ok {
+			delete(meta.Annotations, k)
+			changed = true
+		}
+	}
+
+	// drop the key lists of an empty template
+	for _, k := range []string{annotationTemplateLabels, annotationTemplateAnnotations} {
+		if _, ok := annotations[k]; !ok && meta.Annotations[k] != "" {
+			delete(meta.Annotations, k)
+			changed = true
+		}
+	}
+
+	for k, v := range labels {
+		if meta.Labels[k] != v {
+			meta.Labels[k] = v
+			changed = true
+		}
+	}
+	for k, v := range annotations {
+		if meta.Annotations[k] != v {
+			meta.Annotations[k] = v
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// specHash returns a hash of the spec fields that make up the certificate.
+func specHash(obj *certsv1.Certificate) string {
+	altNames := append([]string{}, obj.Spec.AltNames...)
+	sort.Strings(altNames)
+
+	fields := []interface{}{
+		obj.Spec.Organization,
+		obj.Spec.DNSName,
+		obj.Spec.ValidForDays,
+		altNames,
+	}
+
+	// appended only if set, so that the hash of existing Secrets holds
+	if len(obj.Spec.IPAddresses) > 0 {
+		ips := append([]string{}, obj.Spec.IPAddresses...)
+		sort.Strings(ips)
+		fields = append(fields, ips)
+	}
+	if len(obj.Spec.URIs) > 0 || len(obj.Spec.Usages) > 0 {
+		uris := append([]string{}, obj.Spec.URIs...)
+		sort.Strings(uris)
+		usages := append([]certsv1.Usage{}, obj.Spec.Usages...)
+		slices.Sort(usages)
+		fields = append(fields, map[string]interface{}{"uris": uris, "usages": usages})
+	}
+
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// setTemplateKeys records the keys of a template map in the given annotation,
+// so that they can be removed once dropped from the template.
+func setTemplateKeys(annotations map[string]string, annotation string, tmpl map[string]string) {
+	if len(tmpl) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(tmpl))
+	for k := range tmpl {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	annotations[annotation] = strings.Join(keys, ",")
+}
+
+func templateKeys(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	return strings.Split(value, ",")
+}
+
+func copyMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+
+	return out
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// ServiceReconciler creates a Certificate for the in-cluster DNS names of
+// every Service annotated with certs.k8c.io/secret-name, which the
+// CertificateReconciler then issues into the named Secret.
+type ServiceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ClusterDomain is the DNS domain of the cluster, e.g. cluster.local.
+	ClusterDomain string
+}
+
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+
+func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	var svc corev1.Service
+	if err := r.Get(ctx, req.NamespacedName, &svc); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// owned Certificates are garbage collected along with the Service
+	if !svc.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	var desired []*certsv1.Certificate
+	if name := svc.Annotations[annotationSecretName]; name != "" {
+		desired = append(desired, r.serviceCertificate(&svc, name))
+	}
+
+	requeue, err := syncShimCertificates(ctx, r.Client, r.Scheme, r.Recorder, &svc, desired)
+
+	return reconcile.Result{RequeueAfter: requeue}, err
+}
+
+// serviceCertificate returns the Certificate of the Service, issued for all
+// of its in-cluster DNS names, the fully qualified one being the DNS name,
+// and with certs.k8c.io/cluster-ip-sans, for its cluster IPs.
+func (r *ServiceReconciler) serviceCertificate(svc *corev1.Service, secretName string) *certsv1.Certificate {
+	fqdn := svc.Name + "." + svc.Namespace + ".svc." + r.ClusterDomain
+	crt := shimCertificate(svc, secretName, shimOrganization(svc), []string{
+		fqdn,
+		svc.Name,
+		svc.Name + "." + svc.Namespace,
+		svc.Name + "." + svc.Namespace + ".svc",
+	})
+
+	if svc.Annotations[annotationClusterIPSANs] == "true" {
+		for _, ip := range svc.Spec.ClusterIPs {
+			if ip != "" && ip != corev1.ClusterIPNone {
+				crt.Spec.IPAddresses = append(crt.Spec.IPAddresses, ip)
+			}
+		}
+	}
+
+	return crt
+}
+
+func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("service").
+		For(&corev1.Service{}).
+		Owns(&certsv1.Certificate{}).
+		Complete(r)
+}
@@ -0,0 +1,77 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Service Controller", func() {
+	Context("When a service names a secret", func() {
+		It("Should keep a certificate for its in-cluster DNS names", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "todo",
+					Namespace: ns.Name,
+					Annotations: map[string]string{
+						"certs.k8c.io/secret-name": secretName,
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Port: 443}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, svc)).Should(Succeed())
+
+			key := types.NamespacedName{Name: secretName, Namespace: ns.Name}
+			cert := &certsv1.Certificate{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cert)
+			}, timeout, interval).Should(Succeed())
+
+			fqdn := "todo." + ns.Name + ".svc." + clusterDomain
+			Expect(cert.Spec.DNSName).Should(Equal(fqdn))
+			Expect(cert.Spec.AltNames).Should(ConsistOf(
+				"todo", "todo."+ns.Name, "todo."+ns.Name+".svc", fqdn))
+			Expect(cert.Spec.IPAddresses).Should(BeEmpty())
+
+			// the cluster IPs are added on request
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(svc), svc)).Should(Succeed())
+			svc.Annotations["certs.k8c.io/cluster-ip-sans"] = "true"
+			Expect(k8sClient.Update(ctx, svc)).Should(Succeed())
+
+			Eventually(func() []string {
+				_ = k8sClient.Get(ctx, key, cert)
+				return cert.Spec.IPAddresses
+			}, timeout, interval).Should(Equal(svc.Spec.ClusterIPs))
+
+			// removing the annotation deletes the certificate
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(svc), svc)).Should(Succeed())
+			delete(svc.Annotations, "certs.k8c.io/secret-name")
+			Expect(k8sClient.Update(ctx, svc)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cert)
+			}, timeout, interval).ShouldNot(Succeed())
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
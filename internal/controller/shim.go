@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// shimCertificate returns the Certificate a shim wants for the Secret,
+// issued for the hosts, the first of which is the DNS name. All hosts are
+// repeated in the alternate names, as clients only check those.
+func shimCertificate(owner client.Object, secretName, organization string, hosts []string) *certsv1.Certificate {
+	return &certsv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: owner.GetNamespace(),
+		},
+		Spec: certsv1.CertificateSpec{
+			Organization: organization,
+			DNSName:      hosts[0],
+			AltNames:     hosts,
+			SecretRef:    certsv1.SecretRef{Name: secretName},
+		},
+	}
+}
+
+// shimOrganization returns the organization of the Certificates of a shim;
+// the certs.k8c.io/organization annotation, or the namespace of the owner.
+func shimOrganization(owner client.Object) string {
+	if org := owner.GetAnnotations()[annotationOrganization]; org != "" {
+		return org
+	}
+
+	return owner.GetNamespace()
+}
+
+// syncShimCertificates creates or updates the desired Certificates, controlled
+// by owner, and deletes the other Certificates owner controls. A Certificate
+// of the same name not controlled by owner is left alone, and reported on owner;
+// the returned duration asks to check again, as it may be on its way out.
+func syncShimCertificates(ctx context.Context, c client.Client, scheme *runtime.Scheme,
+	recorder record.EventRecorder, owner client.Object, desired []*certsv1.Certificate) (time.Duration, error) {
+
+	logger := log.FromContext(ctx)
+
+	var list certsv1.CertificateList
+	if err := c.List(ctx, &list, client.InNamespace(owner.GetNamespace())); err != nil {
+		return reconcileNone, err
+	}
+
+	wanted := map[string]bool{}
+	for _, crt := range desired {
+		wanted[crt.Name] = true
+	}
+
+	for i := range list.Items {
+		crt := &list.Items[i]
+		if !metav1.IsControlledBy(crt, owner) || wanted[crt.Name] {
+			continue
+		}
+
+		logger.Info("deleting certificate no longer wanted", "name", crt.Name)
+		if err := c.Delete(ctx, crt, client.Preconditions{UID: &crt.UID}); client.IgnoreNotFound(err) != nil {
+			return reconcileNone, err
+		}
+	}
+
+	requeue := reconcileNone
+	for _, crt := range desired {
+		var existing certsv1.Certificate
+		err := c.Get(ctx, client.ObjectKeyFromObject(crt), &existing)
+		if apierrors.IsNotFound(err) {
+			if err := controllerutil.SetControllerReference(owner, crt, scheme); err != nil {
+				return reconcileNone, err
+			}
+
+			logger.Info("creating certificate", "name", crt.Name)
+			if err := c.Create(ctx, crt); err != nil {
+				return reconcileNone, err
+			}
+			continue
+		}
+		if err != nil {
+			return reconcileNone, err
+		}
+
+		if !metav1.IsControlledBy(&existing, owner) {
+			recorder.Eventf(owner, corev1.EventTypeWarning, reasonCertificateConflict,
+				"certificate %s already exists and is not managed by this object", crt.Name)
+			requeue = reconcileInAMinute
+			continue
+		}
+
+		if existing.Spec.Organization == crt.Spec.Organization &&
+			existing.Spec.DNSName == crt.Spec.DNSName &&
+			slices.Equal(existing.Spec.AltNames, crt.Spec.AltNames) &&
+			slices.Equal(existing.Spec.IPAddresses, crt.Spec.IPAddresses) {
+			continue
+		}
+
+		patch := client.MergeFrom(existing.DeepCopy())
+		existing.Spec.Organization = crt.Spec.Organization
+		existing.Spec.DNSName = crt.Spec.DNSName
+		existing.Spec.AltNames = crt.Spec.AltNames
+		existing.Spec.IPAddresses = crt.Spec.IPAddresses
+
+		logger.Info("updating certificate", "name", crt.Name)
+		if err := c.Patch(ctx, &existing, patch); err != nil {
+			return reconcileNone, err
+		}
+	}
+
+	return requeue, nil
+}
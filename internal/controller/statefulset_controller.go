@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certsv1 "certificate-manager/api/v1"
+)
+
+// placeholders of the Secret name template of a StatefulSet
+const (
+	placeholderPod     = "{pod}"
+	placeholderOrdinal = "{ordinal}"
+)
+
+// StatefulSetReconciler creates a Certificate for every replica of the
+// StatefulSets annotated with certs.k8c.io/pod-secret-name, issued for the
+// stable DNS names of the Pod, and deletes those of removed replicas.
+type StatefulSetReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ClusterDomain is the DNS domain of the cluster, e.g. cluster.local.
+	ClusterDomain string
+}
+
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+
+func (r *StatefulSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	var sts appsv1.StatefulSet
+	if err := r.Get(ctx, req.NamespacedName, &sts); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// owned Certificates are garbage collected along with the StatefulSet
+	if !sts.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	var desired []*certsv1.Certificate
+	if tmpl := sts.Annotations[annotationPodSecretName]; tmpl != "" {
+		if !strings.Contains(tmpl, placeholderPod) && !strings.Contains(tmpl, placeholderOrdinal) {
+			// every replica would share the same Secret; the existing
+			// Certificates are kept until the template is fixed
+			r.Recorder.Eventf(&sts, corev1.EventTypeWarning, reasonInvalidTemplate,
+				"secret name template %q must contain %s or %s", tmpl, placeholderPod, placeholderOrdinal)
+
+			return reconcile.Result{}, nil
+		}
+
+		desired = r.podCertificates(&sts, tmpl)
+	}
+
+	requeue, err := syncShimCertificates(ctx, r.Client, r.Scheme, r.Recorder, &sts, desired)
+
+	return reconcile.Result{RequeueAfter: requeue}, err
+}
+
+// podCertificates returns a Certificate for every replica of the StatefulSet,
+// stored in the Secret named by the template, and issued for the DNS names
+// the governing Service gives the Pod, the fully qualified one being the DNS
+// name.
+func (r *StatefulSetReconciler) podCertificates(sts *appsv1.StatefulSet, tmpl string) []*certsv1.Certificate {
+	if sts.Spec.ServiceName == "" {
+		return nil
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+
+	start := int32(0)
+	if sts.Spec.Ordinals != nil {
+		start = sts.Spec.Ordinals.Start
+	}
+
+	crts := make([]*certsv1.Certificate, 0, replicas)
+	for ordinal := start; ordinal < start+replicas; ordinal++ {
+		pod := sts.Name + "-" + strconv.Itoa(int(ordinal))
+		name := strings.NewReplacer(placeholderPod, pod, placeholderOrdinal, strconv.Itoa(int(ordinal))).Replace(tmpl)
+
+		host := pod + "." + sts.Spec.ServiceName
+		crts = append(crts, shimCertificate(sts, name, shimOrganization(sts), []string{
+			host + "." + sts.Namespace + ".svc." + r.ClusterDomain,
+			host,
+			host + "." + sts.Namespace,
+			host + "." + sts.Namespace + ".svc",
+		}))
+	}
+
+	return crts
+}
+
+func (r *StatefulSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("statefulset").
+		For(&appsv1.StatefulSet{}).
+		Owns(&certsv1.Certificate{}).
+		Complete(r)
+}
@@ -0,0 +1,81 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	"go.uber.org/mock/gomock"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certsv1 "certificate-manager/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatefulSet Controller", func() {
+	Context("When a statefulset asks for per-pod certificates", func() {
+		It("Should keep a certificate per replica", func() {
+			ca.EXPECT().IssueCert(gomock.Any()).AnyTimes().Return(tlsKey, tlsCrt, nil)
+			ca.EXPECT().HasCertificateExpired(gomock.Any()).AnyTimes().Return(false, nil)
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			labels := map[string]string{"app": "kafka"}
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kafka",
+					Namespace: ns.Name,
+					Annotations: map[string]string{
+						"certs.k8c.io/pod-secret-name": "{pod}-tls",
+					},
+				},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:    ptr.To[int32](2),
+					ServiceName: "kafka-headless",
+					Selector:    &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "kafka", Image: "kafka"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, sts)).Should(Succeed())
+
+			for _, pod := range []string{"kafka-0", "kafka-1"} {
+				cert := &certsv1.Certificate{}
+				Eventually(func() error {
+					return k8sClient.Get(ctx, types.NamespacedName{Name: pod + "-tls", Namespace: ns.Name}, cert)
+				}, timeout, interval).Should(Succeed())
+
+				host := pod + ".kafka-headless." + ns.Name + ".svc"
+				Expect(cert.Spec.DNSName).Should(Equal(host + "." + clusterDomain))
+				Expect(cert.Spec.AltNames).Should(ContainElement(host))
+				Expect(cert.Spec.SecretRef.Name).Should(Equal(pod + "-tls"))
+			}
+
+			// scaling down removes the certificate of the removed replica
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(sts), sts)).Should(Succeed())
+			sts.Spec.Replicas = ptr.To[int32](1)
+			Expect(k8sClient.Update(ctx, sts)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: "kafka-1-tls", Namespace: ns.Name},
+					&certsv1.Certificate{})
+			}, timeout, interval).ShouldNot(Succeed())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "kafka-0-tls", Namespace: ns.Name},
+				&certsv1.Certificate{})).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
@@ -5,14 +5,21 @@ package controller_test
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"go.uber.org/mock/gomock"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -40,8 +47,14 @@ var (
 	ca        *mocks.MockCertAuthority
 	tlsKey    []byte
 	tlsCrt    []byte
+	caCrt     []byte
+
+	gatewayAPIInstalled bool
 )
 
+// clusterDomain is deliberately not cluster.local, so that no default slips through.
+const clusterDomain = "k8c.test"
+
 func TestControllers(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -53,8 +66,14 @@ var _ = BeforeSuite(func() {
 	ctx, cancel = context.WithCancel(context.TODO())
 
 	By("bootstrapping test environment")
+	// the Gateway API CRDs are installed by make e2e; tests needing them are skipped otherwise
+	crdPaths := []string{filepath.Join("..", "..", "config", "crd")}
+	if gatewayCRDs := os.Getenv("GATEWAY_API_CRDS"); gatewayCRDs != "" {
+		crdPaths = append(crdPaths, gatewayCRDs)
+	}
+
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd")},
+		CRDDirectoryPaths:     crdPaths,
 		ErrorIfCRDPathMissing: true,
 
 		// The BinaryAssetsDirectory is only required if you want to run the tests directly
@@ -68,6 +87,7 @@ var _ = BeforeSuite(func() {
 
 	// generate a certificate for e2e test
 	trueCA, _ := cert.Authority()
+	caCrt = trueCA.CACert()
 	tlsKey, tlsCrt, _ = trueCA.IssueCert(cert.Request{
 		DNSName:      "test.k8c.io",
 		Organization: "k8c",
@@ -92,18 +112,98 @@ var _ = BeforeSuite(func() {
 
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme: scheme.Scheme,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Secret{}:    controller.SecretCacheOptions(),
+				&corev1.ConfigMap{}: controller.TrustBundleCacheOptions(),
+			},
+		},
 	})
 	Expect(err).ToNot(HaveOccurred())
 
 	mockCtrl = gomock.NewController(GinkgoT())
 	ca = mocks.NewMockCertAuthority(mockCtrl)
+	ca.EXPECT().CACert().AnyTimes().Return(caCrt)
 
 	Expect((&controller.CertificateReconciler{
-		Client: k8sManager.GetClient(),
-		Scheme: k8sManager.GetScheme(),
-		CA:     ca,
+		Client:    k8sManager.GetClient(),
+		APIReader: k8sManager.GetAPIReader(),
+		Scheme:    k8sManager.GetScheme(),
+		Recorder:  k8sManager.GetEventRecorderFor("certificate-controller"),
+		CA:        ca,
+	}).SetupWithManager(k8sManager)).To(Succeed())
+
+	trustBundlePassword := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "trust-bundle-password",
+			Namespace: metav1.NamespaceDefault,
+			Labels:    map[string]string{"certs.k8c.io/managed": "password"},
+		},
+		Data: map[string][]byte{"password": []byte("changeit")},
+	}
+	Expect(k8sClient.Create(ctx, trustBundlePassword)).To(Succeed())
+
+	Expect((&controller.TrustBundleReconciler{
+		Client:         k8sManager.GetClient(),
+		APIReader:      k8sManager.GetAPIReader(),
+		Scheme:         k8sManager.GetScheme(),
+		Recorder:       k8sManager.GetEventRecorderFor("trust-bundle-controller"),
+		CA:             ca,
+		Selector:       labels.SelectorFromSet(labels.Set{trustBundleLabel: "true"}),
+		Formats:        []string{controller.TrustBundleFormatPKCS12},
+		PasswordSecret: client.ObjectKeyFromObject(trustBundlePassword),
+		Overlap:        time.Hour,
+	}).SetupWithManager(k8sManager)).To(Succeed())
+
+	Expect((&controller.IngressReconciler{
+		Client:   k8sManager.GetClient(),
+		Scheme:   k8sManager.GetScheme(),
+		Recorder: k8sManager.GetEventRecorderFor("ingress-shim"),
+	}).SetupWithManager(k8sManager)).To(Succeed())
+
+	Expect((&controller.ServiceReconciler{
+		Client:        k8sManager.GetClient(),
+		Scheme:        k8sManager.GetScheme(),
+		Recorder:      k8sManager.GetEventRecorderFor("service-shim"),
+		ClusterDomain: clusterDomain,
+	}).SetupWithManager(k8sManager)).To(Succeed())
+
+	Expect((&controller.StatefulSetReconciler{
+		Client:        k8sManager.GetClient(),
+		Scheme:        k8sManager.GetScheme(),
+		Recorder:      k8sManager.GetEventRecorderFor("statefulset-shim"),
+		ClusterDomain: clusterDomain,
 	}).SetupWithManager(k8sManager)).To(Succeed())
 
+	gatewayAPIInstalled, err = controller.GatewayAPIInstalled(k8sManager.GetRESTMapper())
+	Expect(err).NotTo(HaveOccurred())
+	if gatewayAPIInstalled {
+		Expect((&controller.GatewayReconciler{
+			Client:   k8sManager.GetClient(),
+			Scheme:   k8sManager.GetScheme(),
+			Recorder: k8sManager.GetEventRecorderFor("gateway-shim"),
+		}).SetupWithManager(k8sManager)).To(Succeed())
+	}
+
+	for _, kind := range controller.InjectableKinds {
+		Expect((&controller.CAInjectorReconciler{
+			Client: k8sManager.GetClient(),
+			CA:     ca,
+			Kind:   kind,
+		}).SetupWithManager(k8sManager)).To(Succeed())
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	for _, kind := range controller.WorkloadKinds {
+		Expect((&controller.RolloutReconciler{
+			Client:   k8sManager.GetClient(),
+			Scheme:   k8sManager.GetScheme(),
+			Recorder: k8sManager.GetEventRecorderFor("rollout-controller"),
+			Kind:     kind,
+			Limiter:  limiter,
+		}).SetupWithManager(k8sManager)).To(Succeed())
+	}
+
 	go func() {
 		defer GinkgoRecover()
 		err = k8sManager.Start(ctx)
@@ -0,0 +1,402 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"certificate-manager/internal/cert"
+)
+
+const (
+	// TrustBundleName is the name of the ConfigMap holding the CA trust bundle.
+	TrustBundleName = "certs-k8c-io-ca.crt"
+
+	// labelTrustBundle marks the trust bundle ConfigMaps; only those are cached.
+	labelTrustBundle = "certs.k8c.io/trust-bundle"
+
+	// annotationPublishedCAs holds the SHA-256 fingerprints of the
+	// CA certificates published in a trust bundle.
+	annotationPublishedCAs = "certs.k8c.io/published-cas"
+
+	// annotationOverlapUntil holds the time until which the previous
+	// CA certificates are kept in a trust bundle.
+	annotationOverlapUntil = "certs.k8c.io/overlap-until"
+
+	// annotationTruststoresHash holds a digest of the truststores written to
+	// a trust bundle, so that manual edits of the salted truststores show.
+	annotationTruststoresHash = "certs.k8c.io/truststores-hash"
+
+	// annotationPasswordVersion holds the resourceVersion of the password
+	// Secret the truststores of a trust bundle are protected with.
+	annotationPasswordVersion = "certs.k8c.io/password-version"
+
+	// TrustBundleFormatJKS and TrustBundleFormatPKCS12 are the optional
+	// truststore variants of the trust bundle.
+	TrustBundleFormatJKS    = "jks"
+	TrustBundleFormatPKCS12 = "pkcs12"
+)
+
+// TrustBundleReconciler publishes the certificates of the CA into a
+// ConfigMap in every namespace matching the selector.
+type TrustBundleReconciler struct {
+	client.Client
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+
+	CA cert.CertAuthority
+
+	// Selector selects the namespaces to publish the trust bundle in.
+	Selector labels.Selector
+
+	// Formats lists the truststore variants to publish next to ca.crt.
+	Formats []string
+
+	// PasswordSecret names the Secret holding the password of the truststore
+	// variants, under the key password. Required if Formats is not empty.
+	// It must be labelled certs.k8c.io/managed=password to be cached.
+	PasswordSecret client.ObjectKey
+
+	// Overlap is the time the previous CA certificates are kept in the
+	// bundle after the CA changed, so that clients trust both until all
+	// certificates have been reissued.
+	Overlap time.Duration
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+// TrustBundleCacheOptions restricts the ConfigMap informer to trust bundles.
+func TrustBundleCacheOptions() cache.ByObject {
+	return cache.ByObject{
+		Label: labels.SelectorFromSet(labels.Set{labelTrustBundle: "true"}),
+	}
+}
+
+func (r *TrustBundleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var ns corev1.Namespace
+	if err := r.Get(ctx, req.NamespacedName, &ns); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	key := client.ObjectKey{Namespace: ns.Name, Name: TrustBundleName}
+
+	var existing corev1.ConfigMap
+	err := r.Get(ctx, key, &existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	found := err == nil
+
+	if !ns.DeletionTimestamp.IsZero() || !r.Selector.Matches(labels.Set(ns.Labels)) {
+		if found {
+			logger.Info("removing trust bundle", "namespace", ns.Name)
+			return reconcile.Result{}, client.IgnoreNotFound(r.Delete(ctx, &existing))
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	var current *corev1.ConfigMap
+	if found {
+		current = &existing
+	}
+
+	desired, requeue, err := r.trustBundle(ctx, &ns, current)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if !found {
+		logger.Info("publishing trust bundle", "namespace", ns.Name)
+
+		err := r.Create(ctx, desired)
+		if apierrors.IsAlreadyExists(err) {
+			return reconcile.Result{}, r.reportConflict(ctx, &ns)
+		}
+
+		return reconcile.Result{RequeueAfter: requeue}, err
+	}
+
+	if !trustBundleChanged(&existing, desired) {
+		return reconcile.Result{RequeueAfter: requeue}, nil
+	}
+
+	logger.Info("updating trust bundle", "namespace", ns.Name)
+	desired.ResourceVersion = existing.ResourceVersion
+
+	return reconcile.Result{RequeueAfter: requeue}, r.Update(ctx, desired)
+}
+
+// reportConflict records a warning on the namespace if the ConfigMap that
+// could not be created is not a trust bundle, as only those are cached. It is
+// left alone, and the namespace is not retried, until it changes again.
+func (r *TrustBundleReconciler) reportConflict(ctx context.Context, ns *corev1.Namespace) error {
+	var existing corev1.ConfigMap
+	if err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: TrustBundleName}, &existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	// a trust bundle missing from the cache yet is picked up by the watch
+	if existing.Labels[labelTrustBundle] == "true" {
+		return nil
+	}
+
+	r.Recorder.Eventf(ns, corev1.EventTypeWarning, reasonTrustBundleConflict,
+		"configmap %s already exists and is not a trust bundle, remove it to publish the trust bundle", TrustBundleName)
+
+	return nil
+}
+
+// password returns the password of the truststore variants, and the
+// resourceVersion of the Secret holding it.
+func (r *TrustBundleReconciler) password(ctx context.Context) (string, string, error) {
+	var sec corev1.Secret
+	if err := r.Get(ctx, r.PasswordSecret, &sec); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", errors.Errorf("trust bundle password secret %s not found, or not labelled %s=%s",
+				r.PasswordSecret, labelManaged, managedPassword)
+		}
+
+		return "", "", errors.Wrapf(err, "unable to get trust bundle password secret %s", r.PasswordSecret)
+	}
+
+	password, ok := sec.Data[defaultPasswordKey]
+	if !ok {
+		return "", "", errors.Errorf("trust bundle password secret %s has no key %s", r.PasswordSecret, defaultPasswordKey)
+	}
+
+	return string(password), sec.ResourceVersion, nil
+}
+
+// trustBundle returns the trust bundle ConfigMap of the namespace, holding
+// the current CA certificate and, during the overlap after a CA change, the
+// previous ones published in current. Returns when the overlap ends, if any.
+func (r *TrustBundleReconciler) trustBundle(ctx context.Context, ns *corev1.Namespace,
+	current *corev1.ConfigMap) (*corev1.ConfigMap, time.Duration, error) {
+
+	caCrt, err := cert.Decode(r.CA.CACert())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	bundle := []*x509.Certificate{caCrt}
+	annotations := map[string]string{}
+
+	if current != nil {
+		previous, until := r.previousCAs(caCrt, current)
+		if len(previous) > 0 {
+			bundle = append(bundle, previous...)
+			annotations[annotationOverlapUntil] = until.UTC().Format(time.RFC3339)
+		}
+	}
+
+	fingerprints := make([]string, 0, len(bundle))
+	var pemBundle []byte
+	for _, crt := range bundle {
+		fingerprints = append(fingerprints, fingerprint(crt))
+		pemBundle = append(pemBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	annotations[annotationPublishedCAs] = strings.Join(fingerprints, ",")
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        TrustBundleName,
+			Namespace:   ns.Name,
+			Labels:      map[string]string{labelTrustBundle: "true"},
+			Annotations: annotations,
+		},
+		Data: map[string]string{caCert: string(pemBundle)},
+	}
+
+	var password string
+	if len(r.Formats) > 0 {
+		if password, cm.Annotations[annotationPasswordVersion], err = r.password(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	for _, format := range r.Formats {
+		if cm.BinaryData == nil {
+			cm.BinaryData = map[string][]byte{}
+		}
+
+		switch format {
+		case TrustBundleFormatJKS:
+			if cm.BinaryData[truststoreJKS], err = cert.JKSTrustStore(bundle, password); err != nil {
+				return nil, 0, err
+			}
+		case TrustBundleFormatPKCS12:
+			if cm.BinaryData[truststoreP12], err = cert.PKCS12TrustStore(bundle, password); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	if len(cm.BinaryData) > 0 {
+		cm.Annotations[annotationTruststoresHash] = binaryDigest(cm.BinaryData)
+	}
+
+	if err := controllerutil.SetControllerReference(ns, cm, r.Scheme); err != nil {
+		return nil, 0, err
+	}
+
+	var requeue time.Duration
+	if until, ok := annotations[annotationOverlapUntil]; ok {
+		t, _ := time.Parse(time.RFC3339, until)
+		requeue = time.Until(t)
+	}
+
+	return cm, requeue, nil
+}
+
+// previousCAs returns the CA certificates other than caCrt published in the
+// trust bundle, as long as they are valid and the overlap has not ended, and
+// the end of the overlap. Certificates added by anyone else are dropped.
+func (r *TrustBundleReconciler) previousCAs(caCrt *x509.Certificate,
+	current *corev1.ConfigMap) ([]*x509.Certificate, time.Time) {
+
+	published := strings.Split(current.Annotations[annotationPublishedCAs], ",")
+
+	// the overlap starts once the trust bundle sees a new CA
+	until, err := time.Parse(time.RFC3339, current.Annotations[annotationOverlapUntil])
+	if !slices.Contains(published, fingerprint(caCrt)) || err != nil {
+		until = time.Now().Add(r.Overlap)
+	}
+	if time.Now().After(until) {
+		return nil, until
+	}
+
+	crts, err := cert.DecodeAll([]byte(current.Data[caCert]))
+	if err != nil {
+		return nil, until
+	}
+
+	var previous []*x509.Certificate
+	for _, crt := range crts {
+		fp := fingerprint(crt)
+		if fp == fingerprint(caCrt) || !slices.Contains(published, fp) ||
+			!crt.IsCA || time.Now().After(crt.NotAfter) {
+			continue
+		}
+
+		previous = append(previous, crt)
+	}
+
+	return previous, until
+}
+
+// trustBundleChanged returns whether the ConfigMap differs from the desired
+// trust bundle. Truststores are salted, so they are compared by the published
+// certificates, the formats and the password Secret, and checked against
+// their digest.
+func trustBundleChanged(current, desired *corev1.ConfigMap) bool {
+	if len(current.BinaryData) > 0 && binaryDigest(current.BinaryData) != current.Annotations[annotationTruststoresHash] {
+		return true
+	}
+
+	if current.Data[caCert] != desired.Data[caCert] ||
+		current.Labels[labelTrustBundle] != desired.Labels[labelTrustBundle] ||
+		len(current.Data) != len(desired.Data) || len(current.BinaryData) != len(desired.BinaryData) {
+		return true
+	}
+
+	for k := range desired.BinaryData {
+		if _, ok := current.BinaryData[k]; !ok {
+			return true
+		}
+	}
+
+	for _, k := range []string{annotationPublishedCAs, annotationOverlapUntil, annotationPasswordVersion} {
+		if current.Annotations[k] != desired.Annotations[k] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// binaryDigest returns the SHA-256 digest of the binary data, in key order.
+func binaryDigest(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write(data[k])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprint returns the SHA-256 fingerprint of the certificate.
+func fingerprint(crt *x509.Certificate) string {
+	sum := sha256.Sum256(crt.Raw)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// namespacesForPasswordSecret maps the password Secret to the namespaces
+// the trust bundle is published in, so that a rotated password is applied.
+func (r *TrustBundleReconciler) namespacesForPasswordSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if client.ObjectKeyFromObject(obj) != r.PasswordSecret {
+		return nil
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := r.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: r.Selector}); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+	}
+
+	return requests
+}
+
+func (r *TrustBundleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("trustbundle").
+		For(&corev1.Namespace{}).
+		Owns(&corev1.ConfigMap{})
+
+	if len(r.Formats) > 0 {
+		b = b.Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.namespacesForPasswordSecret),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}, isPasswordSecret),
+		)
+	}
+
+	return b.Complete(r)
+}
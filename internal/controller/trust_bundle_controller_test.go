@@ -0,0 +1,98 @@
+//go:build e2e
+
+package controller_test
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"certificate-manager/internal/controller"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// trustBundleLabel selects the namespaces the trust bundle is published in.
+const trustBundleLabel = "trust-bundle"
+
+var _ = Describe("Trust Bundle Controller", func() {
+	Context("When a namespace matches the selector", func() {
+		It("Should publish and repair the trust bundle", func() {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: certificateNamespace,
+					Labels:       map[string]string{trustBundleLabel: "true"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			key := types.NamespacedName{Name: controller.TrustBundleName, Namespace: ns.Name}
+			cm := &corev1.ConfigMap{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cm)
+			}, timeout, interval).Should(Succeed())
+			Expect(cm.Data).Should(HaveKeyWithValue("ca.crt", string(caCrt)))
+			Expect(cm.BinaryData).Should(HaveKey("truststore.p12"))
+
+			// manual edits are reverted
+			cm.Data["ca.crt"] = "edited"
+			Expect(k8sClient.Update(ctx, cm)).Should(Succeed())
+
+			Eventually(func() string {
+				_ = k8sClient.Get(ctx, key, cm)
+				return cm.Data["ca.crt"]
+			}, timeout, interval).Should(Equal(string(caCrt)))
+
+			// the trust bundle is removed once the namespace no longer matches
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ns.Name}, ns)).Should(Succeed())
+			delete(ns.Labels, trustBundleLabel)
+			Expect(k8sClient.Update(ctx, ns)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, key, cm)
+			}, timeout, interval).ShouldNot(Succeed())
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+
+		It("Should leave a configmap of the same name alone", func() {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: certificateNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).Should(Succeed())
+
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: controller.TrustBundleName, Namespace: ns.Name},
+				Data:       map[string]string{"ca.crt": "other"},
+			}
+			Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+
+			ns.Labels = map[string]string{trustBundleLabel: "true"}
+			Expect(k8sClient.Update(ctx, ns)).Should(Succeed())
+
+			Eventually(func() bool {
+				var events corev1.EventList
+				if err := k8sClient.List(ctx, &events); err != nil {
+					return false
+				}
+
+				for _, e := range events.Items {
+					if e.InvolvedObject.Name == ns.Name && e.Reason == "TrustBundleConflict" {
+						return true
+					}
+				}
+
+				return false
+			}, timeout, interval).Should(BeTrue())
+
+			key := types.NamespacedName{Name: controller.TrustBundleName, Namespace: ns.Name}
+			Consistently(func() string {
+				_ = k8sClient.Get(ctx, key, cm)
+				return cm.Data["ca.crt"]
+			}, 2*interval, interval).Should(Equal("other"))
+
+			Expect(k8sClient.Delete(ctx, ns)).Should(Succeed())
+		})
+	})
+})
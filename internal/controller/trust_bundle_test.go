@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"certificate-manager/internal/cert"
+)
+
+func TestTrustBundlePasswordRotation(t *testing.T) {
+	ctx := context.Background()
+
+	ca, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	password := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "truststore-password",
+			Namespace: "certs",
+			Labels:    map[string]string{labelManaged: managedPassword},
+		},
+		Data: map[string][]byte{defaultPasswordKey: []byte("changeit")},
+	}
+	selected := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "todo",
+		Labels: map[string]string{"certs.k8c.io/trust": "true"},
+	}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(password, selected, other).Build()
+	r := &TrustBundleReconciler{
+		Client:         c,
+		APIReader:      c,
+		Scheme:         scheme,
+		Recorder:       record.NewFakeRecorder(10),
+		CA:             ca,
+		Selector:       labels.SelectorFromSet(labels.Set{"certs.k8c.io/trust": "true"}),
+		Formats:        []string{TrustBundleFormatPKCS12},
+		PasswordSecret: client.ObjectKeyFromObject(password),
+	}
+
+	// reconcile publishes the trust bundle, and checks its truststore opens
+	// with the password
+	reconcile := func(password string) {
+		t.Helper()
+
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(selected)}); err != nil {
+			t.Fatalf("unable to reconcile: %v", err)
+		}
+
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, client.ObjectKey{Namespace: selected.Name, Name: TrustBundleName}, &cm); err != nil {
+			t.Fatalf("unable to get trust bundle: %v", err)
+		}
+		if _, err := pkcs12.DecodeTrustStore(cm.BinaryData[truststoreP12], password); err != nil {
+			t.Fatalf("unable to decode truststore with password %q: %v", password, err)
+		}
+	}
+
+	reconcile("changeit")
+
+	password.Data[defaultPasswordKey] = []byte("rotated")
+	if err := c.Update(ctx, password); err != nil {
+		t.Fatalf("unable to rotate password: %v", err)
+	}
+
+	// the rotated password enqueues the selected namespaces only
+	requests := r.namespacesForPasswordSecret(ctx, password)
+	if len(requests) != 1 || requests[0].Name != selected.Name {
+		t.Fatalf("expected namespace %s to be enqueued, got %v", selected.Name, requests)
+	}
+
+	unrelated := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "keystore-password", Namespace: "certs"}}
+	if requests := r.namespacesForPasswordSecret(ctx, unrelated); len(requests) != 0 {
+		t.Fatalf("expected another password secret not to enqueue namespaces, got %v", requests)
+	}
+
+	reconcile("rotated")
+}
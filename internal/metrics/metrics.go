@@ -0,0 +1,148 @@
+// Package metrics defines the Prometheus metrics exposed by the
+// certificate-manager on the metrics endpoint of the controller manager.
+package metrics
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "certificate_manager"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	certificateNotAfter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_not_after_timestamp_seconds",
+		Help:      "The time after which the certificate expires, in seconds since the epoch.",
+	}, []string{"namespace", "name", "issuer"})
+
+	certificateNotBefore = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_not_before_timestamp_seconds",
+		Help:      "The time before which the certificate is not valid, in seconds since the epoch.",
+	}, []string{"namespace", "name", "issuer"})
+
+	certificateReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_ready_status",
+		Help:      "Whether the certificate holds valid credentials (1) or not (0).",
+	}, []string{"namespace", "name", "issuer"})
+
+	issuanceTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "certificate_issuance_total",
+		Help:      "The number of first-time certificate issuances, by result.",
+	}, []string{"result"})
+
+	renewalTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "certificate_renewal_total",
+		Help:      "The number of certificate renewals and reissues, by result.",
+	}, []string{"result"})
+
+	// RSA-4096 key generation dominates the issuance time, and
+	// takes anything from a few hundred milliseconds to several seconds.
+	issueDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ca_issue_duration_seconds",
+		Help:      "The time taken by the certificate authority to issue a certificate.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	})
+
+	caNotAfter = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ca_not_after_timestamp_seconds",
+		Help:      "The time after which the CA certificate expires, in seconds since the epoch.",
+	})
+
+	caNotBefore = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ca_not_before_timestamp_seconds",
+		Help:      "The time before which the CA certificate is not valid, in seconds since the epoch.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		certificateNotAfter,
+		certificateNotBefore,
+		certificateReady,
+		issuanceTotal,
+		renewalTotal,
+		issueDuration,
+		caNotAfter,
+		caNotBefore,
+	)
+}
+
+// RecordCertificate records the validity of the certificate
+// held by the Certificate namespace/name.
+func RecordCertificate(ns, name string, crt *x509.Certificate, ready bool) {
+	issuer := IssuerName(crt.Issuer)
+
+	certificateNotAfter.WithLabelValues(ns, name, issuer).Set(float64(crt.NotAfter.Unix()))
+	certificateNotBefore.WithLabelValues(ns, name, issuer).Set(float64(crt.NotBefore.Unix()))
+	RecordReady(ns, name, issuer, ready)
+}
+
+// RecordReady records whether the Certificate namespace/name holds valid credentials.
+func RecordReady(ns, name, issuer string, ready bool) {
+	var value float64
+	if ready {
+		value = 1
+	}
+
+	certificateReady.WithLabelValues(ns, name, issuer).Set(value)
+}
+
+// ForgetCertificate removes all series of the Certificate namespace/name.
+func ForgetCertificate(ns, name string) {
+	labels := prometheus.Labels{"namespace": ns, "name": name}
+
+	certificateNotAfter.DeletePartialMatch(labels)
+	certificateNotBefore.DeletePartialMatch(labels)
+	certificateReady.DeletePartialMatch(labels)
+}
+
+// ObserveIssue records the duration and result of a single issuance.
+// A renewal is any issuance for a Certificate that held credentials before.
+func ObserveIssue(renewal bool, duration time.Duration, err error) {
+	issueDuration.Observe(duration.Seconds())
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+
+	if renewal {
+		renewalTotal.WithLabelValues(result).Inc()
+		return
+	}
+
+	issuanceTotal.WithLabelValues(result).Inc()
+}
+
+// RecordCA records the validity of the CA certificate.
+func RecordCA(crt *x509.Certificate) {
+	caNotAfter.Set(float64(crt.NotAfter.Unix()))
+	caNotBefore.Set(float64(crt.NotBefore.Unix()))
+}
+
+// IssuerName returns the value of the issuer label for the given
+// distinguished name; its common name or, if empty, its organization.
+func IssuerName(name pkix.Name) string {
+	if name.CommonName != "" || len(name.Organization) == 0 {
+		return name.CommonName
+	}
+
+	return name.Organization[0]
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func certificate(issuer pkix.Name, notBefore, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{Issuer: issuer, NotBefore: notBefore, NotAfter: notAfter}
+}
+
+func TestRecordCertificate(t *testing.T) {
+	notBefore := time.Unix(1700000000, 0)
+	notAfter := notBefore.Add(90 * 24 * time.Hour)
+	crt := certificate(pkix.Name{CommonName: "certificate-manager"}, notBefore, notAfter)
+
+	RecordCertificate("todo", "todo-app", crt, true)
+	defer ForgetCertificate("todo", "todo-app")
+
+	expected := `
+# HELP certificate_manager_certificate_not_after_timestamp_seconds The time after which the certificate expires, in seconds since the epoch.
+# TYPE certificate_manager_certificate_not_after_timestamp_seconds gauge
+certificate_manager_certificate_not_after_timestamp_seconds{issuer="certificate-manager",name="todo-app",namespace="todo"} 1.707776e+09
+# HELP certificate_manager_certificate_not_before_timestamp_seconds The time before which the certificate is not valid, in seconds since the epoch.
+# TYPE certificate_manager_certificate_not_before_timestamp_seconds gauge
+certificate_manager_certificate_not_before_timestamp_seconds{issuer="certificate-manager",name="todo-app",namespace="todo"} 1.7e+09
+# HELP certificate_manager_certificate_ready_status Whether the certificate holds valid credentials (1) or not (0).
+# TYPE certificate_manager_certificate_ready_status gauge
+certificate_manager_certificate_ready_status{issuer="certificate-manager",name="todo-app",namespace="todo"} 1
+`
+	if err := testutil.CollectAndCompare(certificateNotAfter, strings.NewReader(expected),
+		"certificate_manager_certificate_not_after_timestamp_seconds"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(certificateNotBefore, strings.NewReader(expected),
+		"certificate_manager_certificate_not_before_timestamp_seconds"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(certificateReady, strings.NewReader(expected),
+		"certificate_manager_certificate_ready_status"); err != nil {
+		t.Fatal(err)
+	}
+
+	RecordReady("todo", "todo-app", "certificate-manager", false)
+	if got := testutil.ToFloat64(certificateReady.WithLabelValues("todo", "todo-app", "certificate-manager")); got != 0 {
+		t.Fatalf("expected the certificate not to be ready, got %v", got)
+	}
+
+	// all series of the Certificate are gone once it is forgotten
+	ForgetCertificate("todo", "todo-app")
+	if n := testutil.CollectAndCount(certificateNotAfter) + testutil.CollectAndCount(certificateNotBefore) +
+		testutil.CollectAndCount(certificateReady); n != 0 {
+		t.Fatalf("expected no series after forgetting the certificate, got %d", n)
+	}
+}
+
+func TestObserveIssue(t *testing.T) {
+	issued := testutil.ToFloat64(issuanceTotal.WithLabelValues(resultSuccess))
+	renewed := testutil.ToFloat64(renewalTotal.WithLabelValues(resultSuccess))
+	failed := testutil.ToFloat64(renewalTotal.WithLabelValues(resultFailure))
+
+	ObserveIssue(false, time.Second, nil)
+	ObserveIssue(true, time.Second, nil)
+	ObserveIssue(true, time.Second, errors.New("CA unavailable"))
+
+	if got := testutil.ToFloat64(issuanceTotal.WithLabelValues(resultSuccess)) - issued; got != 1 {
+		t.Fatalf("expected 1 successful issuance, got %v", got)
+	}
+	if got := testutil.ToFloat64(renewalTotal.WithLabelValues(resultSuccess)) - renewed; got != 1 {
+		t.Fatalf("expected 1 successful renewal, got %v", got)
+	}
+	if got := testutil.ToFloat64(renewalTotal.WithLabelValues(resultFailure)) - failed; got != 1 {
+		t.Fatalf("expected 1 failed renewal, got %v", got)
+	}
+
+	// every issuance is observed, whatever its result
+	if n := testutil.CollectAndCount(issueDuration); n != 1 {
+		t.Fatalf("expected the issue duration histogram, got %d series", n)
+	}
+}
+
+func TestRecordCA(t *testing.T) {
+	notBefore := time.Unix(1700000000, 0)
+	notAfter := notBefore.Add(365 * 24 * time.Hour)
+
+	RecordCA(certificate(pkix.Name{}, notBefore, notAfter))
+
+	if got := testutil.ToFloat64(caNotAfter); got != float64(notAfter.Unix()) {
+		t.Fatalf("expected CA expiry %d, got %v", notAfter.Unix(), got)
+	}
+	if got := testutil.ToFloat64(caNotBefore); got != float64(notBefore.Unix()) {
+		t.Fatalf("expected CA start %d, got %v", notBefore.Unix(), got)
+	}
+}
+
+func TestIssuerName(t *testing.T) {
+	tests := []struct {
+		name pkix.Name
+		want string
+	}{
+		{name: pkix.Name{CommonName: "ca", Organization: []string{"k8c"}}, want: "ca"},
+		{name: pkix.Name{Organization: []string{"certificate-manager"}}, want: "certificate-manager"},
+		{name: pkix.Name{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := IssuerName(tt.name); got != tt.want {
+			t.Errorf("IssuerName(%v) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Package certwatcher keeps the credentials mounted from the Secret of a
+// Certificate in memory, and reloads them once they are renewed, so that
+// servers and clients pick up a new certificate without a restart.
+//
+// Kubernetes updates a mounted Secret by writing its files into a new
+// directory, and swapping the ..data symlink over to it. The watcher reads
+// all files through that symlink, resolved once per reload, so that it never
+// pairs the certificate of one revision with the key of another.
+package certwatcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// The names of the files of a Secret written by the certificate manager.
+const (
+	CertFile = "tls.crt"
+	KeyFile  = "tls.key"
+	CAFile   = "ca.crt"
+)
+
+const (
+	// dataDir is the symlink Kubernetes swaps to update a mounted Secret.
+	dataDir = "..data"
+
+	// retryInterval is the time after which a failed reload is retried,
+	// in case no further change of the files follows.
+	retryInterval = time.Second
+)
+
+// Options configures a Watcher.
+type Options struct {
+	// CertFile, KeyFile and CAFile are the names of the files in the
+	// directory; tls.crt, tls.key and ca.crt if empty.
+	CertFile string
+	KeyFile  string
+	CAFile   string
+
+	// OnReload, if set, is called after every attempt to reload changed
+	// credentials, with the error if it failed. The previous credentials
+	// are kept in use then.
+	OnReload func(err error)
+}
+
+// Watcher holds the certificate, private key and CA certificates of a
+// directory, as mounted from a Secret, and reloads them as they change.
+type Watcher struct {
+	dir  string
+	opts Options
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+	raw  [][]byte
+}
+
+// New returns a Watcher for the credentials in dir, and loads them.
+// Call Start to pick up changes.
+func New(dir string, opts Options) (*Watcher, error) {
+	if opts.CertFile == "" {
+		opts.CertFile = CertFile
+	}
+	if opts.KeyFile == "" {
+		opts.KeyFile = KeyFile
+	}
+	if opts.CAFile == "" {
+		opts.CAFile = CAFile
+	}
+
+	w := &Watcher{dir: dir, opts: opts}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Start watches the directory, and reloads the credentials whenever it
+// changes, until the context is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "unable to create file watcher")
+	}
+	defer watcher.Close()
+
+	// the directory is watched rather than the files, which are replaced
+	// rather than written to
+	if err := watcher.Add(w.dir); err != nil {
+		return errors.Wrapf(err, "unable to watch %s", w.dir)
+	}
+
+	// the files may have changed before they were watched
+	retry := time.After(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+		case <-retry:
+		case _, ok := <-watcher.Errors:
+			// events may have been missed
+			if !ok {
+				return nil
+			}
+		}
+
+		retry = nil
+		changed, err := w.reload()
+		if err != nil {
+			retry = time.After(retryInterval)
+		}
+		if (changed || err != nil) && w.opts.OnReload != nil {
+			w.opts.OnReload(err)
+		}
+	}
+}
+
+// reload reads the credentials, and swaps them in if they changed.
+// Returns whether they did.
+func (w *Watcher) reload() (bool, error) {
+	dir := w.dir
+	if resolved, err := filepath.EvalSymlinks(filepath.Join(w.dir, dataDir)); err == nil {
+		dir = resolved
+	}
+
+	names := []string{w.opts.CertFile, w.opts.KeyFile, w.opts.CAFile}
+	raw := make([][]byte, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to read %s", name)
+		}
+
+		raw = append(raw, data)
+	}
+
+	w.mu.RLock()
+	unchanged := w.raw != nil && bytes.Equal(raw[0], w.raw[0]) &&
+		bytes.Equal(raw[1], w.raw[1]) && bytes.Equal(raw[2], w.raw[2])
+	w.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(raw[0], raw[1])
+	if err != nil {
+		return false, errors.Wrap(err, "unable to load key pair")
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return false, errors.Wrap(err, "unable to parse certificate")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw[2]) {
+		return false, errors.Errorf("no CA certificate in %s", w.opts.CAFile)
+	}
+
+	w.mu.Lock()
+	w.cert, w.pool, w.raw = &cert, pool, raw
+	w.mu.Unlock()
+
+	return true, nil
+}
+
+// Certificate returns the current certificate and private key.
+func (w *Watcher) Certificate() *tls.Certificate {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.cert
+}
+
+// CAPool returns the current pool of CA certificates. The pool is
+// replaced rather than modified on reload.
+func (w *Watcher) CAPool() *x509.CertPool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.pool
+}
+
+// GetCertificate is the tls.Config.GetCertificate hook of servers.
+func (w *Watcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return w.Certificate(), nil
+}
+
+// GetClientCertificate is the tls.Config.GetClientCertificate hook of clients.
+func (w *Watcher) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return w.Certificate(), nil
+}
+
+// VerifyConnection is the tls.Config.VerifyConnection hook of servers, which
+// verifies the certificate of the client against the current CA certificates.
+// tls.Config.ClientCAs and RootCAs cannot change once in use, so servers
+// set ClientAuth to tls.RequireAnyClientCert instead, and leave verifying
+// to this hook. Unlike VerifyPeerCertificate, it is called on resumed
+// sessions as well, so that a client is verified against the CA
+// certificates current at the time, not those it first connected with.
+// The peer must be a client: a certificate restricted to other extended key
+// usages is rejected.
+func (w *Watcher) VerifyConnection(cs tls.ConnectionState) error {
+	return w.verify(rawCertificates(cs), "", x509.ExtKeyUsageClientAuth)
+}
+
+// rawCertificates returns the certificates the peer presented, in DER.
+func rawCertificates(cs tls.ConnectionState) [][]byte {
+	rawCerts := make([][]byte, 0, len(cs.PeerCertificates))
+	for _, cert := range cs.PeerCertificates {
+		rawCerts = append(rawCerts, cert.Raw)
+	}
+
+	return rawCerts
+}
+
+// verify verifies the chain of raw certificates against the current CA
+// certificates, for the given extended key usage, and the DNS name of the
+// leaf, if given. Certificates without extended key usages are valid for any.
+func (w *Watcher) verify(rawCerts [][]byte, dnsName string, usage x509.ExtKeyUsage) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no peer certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return errors.Wrap(err, "unable to parse peer certificate")
+		}
+
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         w.CAPool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+
+	return err
+}
+
+// ServerConfig returns a TLS configuration serving the current certificate.
+// With clientAuth, clients must present a certificate for client usage issued
+// by the current CA certificates; see VerifyConnection.
+func (w *Watcher) ServerConfig(clientAuth bool) *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: w.GetCertificate,
+	}
+
+	if clientAuth {
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.VerifyConnection = w.VerifyConnection
+	}
+
+	return config
+}
+
+// ClientConfig returns a TLS configuration presenting the current certificate
+// to servers asking for one, and verifying the server against the current CA
+// certificates and serverName. The server certificate must allow server usage.
+func (w *Watcher) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           serverName,
+		GetClientCertificate: w.GetClientCertificate,
+
+		// RootCAs cannot change once in use; the server is verified below
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				return errors.New("no server name to verify")
+			}
+
+			return w.verify(rawCertificates(cs), cs.ServerName, x509.ExtKeyUsageServerAuth)
+		},
+	}
+}
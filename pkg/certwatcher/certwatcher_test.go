@@ -0,0 +1,283 @@
+package certwatcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"certificate-manager/internal/cert"
+)
+
+// writeSecret writes the credentials into dir the way the kubelet updates
+// a mounted Secret: into a new directory, then swapping the ..data symlink.
+func writeSecret(t *testing.T, dir, revision string, key, crt, ca []byte) {
+	t.Helper()
+
+	rev := "..rev_" + revision
+	if err := os.Mkdir(filepath.Join(dir, rev), 0o755); err != nil {
+		t.Fatalf("unable to create revision directory: %v", err)
+	}
+
+	for name, data := range map[string][]byte{KeyFile: key, CertFile: crt, CAFile: ca} {
+		if err := os.WriteFile(filepath.Join(dir, rev, name), data, 0o600); err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+
+		// the files point into ..data, which is only created once
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join(dataDir, name), link); err != nil {
+				t.Fatalf("unable to link %s: %v", name, err)
+			}
+		}
+	}
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(rev, tmp); err != nil {
+		t.Fatalf("unable to link revision: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, dataDir)); err != nil {
+		t.Fatalf("unable to swap revision: %v", err)
+	}
+}
+
+func issue(t *testing.T, ca cert.CertAuthority, dnsName string, usages ...x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, crt, err := ca.IssueCert(cert.Request{
+		Organization: "k8c",
+		DNSName:      dnsName,
+		AltNames:     []string{dnsName},
+		ValidForDays: 1,
+		ExtKeyUsages: usages,
+	})
+	if err != nil {
+		t.Fatalf("unable to issue certificate: %v", err)
+	}
+
+	return key, crt
+}
+
+func TestReload(t *testing.T) {
+	ca, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	dir := t.TempDir()
+	key, crt := issue(t, ca, "todo-app.k8c.io")
+	writeSecret(t, dir, "1", key, crt, ca.CACert())
+
+	reloaded := make(chan error, 10)
+	w, err := New(dir, Options{OnReload: func(err error) { reloaded <- err }})
+	if err != nil {
+		t.Fatalf("unable to create watcher: %v", err)
+	}
+	first := w.Certificate()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := w.Start(ctx); err != nil {
+			t.Errorf("unable to watch: %v", err)
+		}
+	}()
+
+	// give the watcher time to watch the directory before the swap
+	time.Sleep(100 * time.Millisecond)
+
+	key, crt = issue(t, ca, "todo-app.k8c.io")
+	writeSecret(t, dir, "2", key, crt, ca.CACert())
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("unable to reload: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the renewed certificate to be reloaded")
+	}
+
+	renewed := w.Certificate()
+	if renewed.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) == 0 {
+		t.Fatal("expected the renewed certificate to be served")
+	}
+
+	if _, err := tls.X509KeyPair(crt, key); err != nil || string(renewed.Certificate[0]) == string(first.Certificate[0]) {
+		t.Fatal("expected the key pair of the renewed certificate")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	watcher := func(dnsName string, usages ...x509.ExtKeyUsage) *Watcher {
+		dir := t.TempDir()
+		key, crt := issue(t, ca, dnsName, usages...)
+		writeSecret(t, dir, "1", key, crt, ca.CACert())
+
+		w, err := New(dir, Options{})
+		if err != nil {
+			t.Fatalf("unable to create watcher: %v", err)
+		}
+
+		return w
+	}
+	server := watcher("todo-app.k8c.io", x509.ExtKeyUsageServerAuth)
+	client := watcher("client.k8c.io", x509.ExtKeyUsageClientAuth)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// handshake returns the error of the client, or else of the server
+	handshake := func(clientConfig *tls.Config) error {
+		done := make(chan error, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				done <- err
+				return
+			}
+			defer conn.Close()
+
+			done <- tls.Server(conn, server.ServerConfig(true)).Handshake()
+		}()
+
+		conn, err := tls.Dial("tcp", ln.Addr().String(), clientConfig)
+		if err != nil {
+			<-done
+			return err
+		}
+		defer conn.Close()
+
+		return <-done
+	}
+
+	if err := handshake(client.ClientConfig("todo-app.k8c.io")); err != nil {
+		t.Fatalf("expected the handshake to succeed: %v", err)
+	}
+
+	if err := handshake(client.ClientConfig("other.k8c.io")); err == nil {
+		t.Fatal("expected a server of another name to be rejected")
+	}
+
+	// a client without a certificate is rejected by the server
+	anonymous := client.ClientConfig("todo-app.k8c.io")
+	anonymous.GetClientCertificate = nil
+	if err := handshake(anonymous); err == nil {
+		t.Fatal("expected a client without a certificate to be rejected")
+	}
+
+	// a server certificate is not valid for clients, and the other way around
+	if err := handshake(server.ClientConfig("todo-app.k8c.io")); err == nil {
+		t.Fatal("expected a client with a server certificate to be rejected")
+	}
+	if err := server.verify(client.Certificate().Certificate, "client.k8c.io", x509.ExtKeyUsageServerAuth); err == nil {
+		t.Fatal("expected a server with a client certificate to be rejected")
+	}
+}
+
+func TestMutualTLSResumption(t *testing.T) {
+	ca, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+
+	serverDir := t.TempDir()
+	serverKey, serverCrt := issue(t, ca, "todo-app.k8c.io", x509.ExtKeyUsageServerAuth)
+	writeSecret(t, serverDir, "1", serverKey, serverCrt, ca.CACert())
+	server, err := New(serverDir, Options{})
+	if err != nil {
+		t.Fatalf("unable to create watcher: %v", err)
+	}
+
+	clientDir := t.TempDir()
+	key, crt := issue(t, ca, "client.k8c.io", x509.ExtKeyUsageClientAuth)
+	writeSecret(t, clientDir, "1", key, crt, ca.CACert())
+	client, err := New(clientDir, Options{})
+	if err != nil {
+		t.Fatalf("unable to create watcher: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// sessions can only be resumed with the ticket keys of the same config
+	serverConfig := server.ServerConfig(true)
+	clientConfig := client.ClientConfig("todo-app.k8c.io")
+	clientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+
+	// handshake returns whether the session was resumed, and the error of
+	// the server, or else of the client
+	handshake := func() (bool, error) {
+		done := make(chan error, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				done <- err
+				return
+			}
+			defer conn.Close()
+
+			tlsConn := tls.Server(conn, serverConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				done <- err
+				return
+			}
+
+			// the session ticket is sent after the handshake
+			_, err = tlsConn.Write([]byte("ok"))
+			done <- err
+		}()
+
+		conn, err := tls.Dial("tcp", ln.Addr().String(), clientConfig)
+		if err == nil {
+			defer conn.Close()
+			_, err = conn.Read(make([]byte, 2))
+		}
+		if serverErr := <-done; serverErr != nil {
+			return false, serverErr
+		}
+		if err != nil {
+			return false, err
+		}
+
+		return conn.ConnectionState().DidResume, nil
+	}
+
+	if _, err := handshake(); err != nil {
+		t.Fatalf("expected the handshake to succeed: %v", err)
+	}
+	if resumed, err := handshake(); err != nil || !resumed {
+		t.Fatalf("expected the session to be resumed, resumed: %t, err: %v", resumed, err)
+	}
+
+	// once the server no longer trusts the CA of the client, neither is the
+	// session of the client resumed
+	other, err := cert.Authority()
+	if err != nil {
+		t.Fatalf("unable to create CA: %v", err)
+	}
+	writeSecret(t, serverDir, "2", serverKey, serverCrt, other.CACert())
+	if changed, err := server.reload(); err != nil || !changed {
+		t.Fatalf("expected the CA of the server to be replaced, changed: %t, err: %v", changed, err)
+	}
+
+	if _, err := handshake(); err == nil {
+		t.Fatal("expected the resumed session of an untrusted client to be rejected")
+	}
+}
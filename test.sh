@@ -1,35 +1,46 @@
 #!/bin/bash
 
-# Script to extract tls.crt from a Kubernetes secret,
-# create a port-forward, test the service over HTTPS,
-# and then clean up.
+# Script to extract the client credentials and ca.crt from
+# Kubernetes secrets, create a port-forward, test the service
+# over mutual TLS, and then clean up.
 
 # Exit script on any error
 set -e
 
 # Configuration variables
 SECRET_NAME="todo-app"
+CLIENT_SECRET_NAME="todo-client"
 NAMESPACE="todo"
 LOCAL_PORT=8443
 SERVICE_PORT=443
 SERVICE_NAME="todo-app"
 
-# Function to extract the TLS certificate from the K8s secret
+# Function to extract the issuing CA certificate and the client
+# credentials from the K8s secrets
 extract_certificate() {
-  echo "Extracting TLS certificate from secret '${SECRET_NAME}' in namespace '${NAMESPACE}'..."
-  kubectl get secret -n ${NAMESPACE} ${SECRET_NAME} -o jsonpath='{.data.tls\.crt}' | base64 -d > tls.crt
+  echo "Extracting CA certificate from secret '${SECRET_NAME}' in namespace '${NAMESPACE}'..."
+  kubectl get secret -n ${NAMESPACE} ${SECRET_NAME} -o jsonpath='{.data.ca\.crt}' | base64 -d > ca.crt
+
+  echo "Extracting client credentials from secret '${CLIENT_SECRET_NAME}' in namespace '${NAMESPACE}'..."
+  kubectl get secret -n ${NAMESPACE} ${CLIENT_SECRET_NAME} -o jsonpath='{.data.tls\.crt}' | base64 -d > client.crt
+  kubectl get secret -n ${NAMESPACE} ${CLIENT_SECRET_NAME} -o jsonpath='{.data.tls\.key}' | base64 -d > client.key
+
+  # the certificate of the server is not valid for clients
+  kubectl get secret -n ${NAMESPACE} ${SECRET_NAME} -o jsonpath='{.data.tls\.crt}' | base64 -d > server.crt
+  kubectl get secret -n ${NAMESPACE} ${SECRET_NAME} -o jsonpath='{.data.tls\.key}' | base64 -d > server.key
 }
 
 # Function to clean up background port-forward process
 cleanup() {
   echo "Cleaning up port-forward process"
   kill ${PID}
+  rm -f client.crt client.key server.crt server.key
 }
 
 # Trap the EXIT signal to call the cleanup function
 trap "cleanup" EXIT
 
-# Extract the TLS certificate and save it to tls.crt
+# Extract the CA certificate and the client credentials
 extract_certificate
 
 # Create a port-forward for the todo-app service
@@ -43,8 +54,20 @@ PID=$!
 echo "Waiting for port-forward to establish..."
 sleep 1
 
-# Perform the curl test
-echo "Testing if the service responds over HTTPS..."
-curl -s --cacert tls.crt https://localhost:${LOCAL_PORT}/todo | jq .
+# Perform the curl tests
+echo "Testing if the service responds to the allowed client..."
+curl -sf --cacert ca.crt --cert client.crt --key client.key https://localhost:${LOCAL_PORT}/todo | jq .
+
+echo "Testing if the service rejects a client without a certificate..."
+if curl -sf --cacert ca.crt https://localhost:${LOCAL_PORT}/todo >/dev/null; then
+  echo "Expected the client without a certificate to be rejected"
+  exit 1
+fi
+
+echo "Testing if the service rejects the server certificate as a client..."
+if curl -sf --cacert ca.crt --cert server.crt --key server.key https://localhost:${LOCAL_PORT}/todo >/dev/null; then
+  echo "Expected the server certificate to be rejected"
+  exit 1
+fi
 
 # Cleanup will automatically be called when the script exits due to the trap.
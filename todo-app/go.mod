@@ -2,4 +2,15 @@ module todo-app
 
 go 1.22.4
 
-require github.com/google/uuid v1.6.0
+require (
+	certificate-manager v0.0.0
+	github.com/google/uuid v1.6.0
+)
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
+
+replace certificate-manager => ../
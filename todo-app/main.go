@@ -1,33 +1,106 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
-	"path/filepath"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"certificate-manager/pkg/certwatcher"
 )
 
-const port = ":443"
+const (
+	port = ":443"
+
+	// certDir is where the todo-app Secret is mounted
+	certDir = "/tmp/certs"
 
-var (
-	certFile = filepath.Join("tmp", "certs", "tls.crt")
-	keyFile  = filepath.Join("tmp", "certs", "tls.key")
+	// allowedClientsEnv lists the DNS names and URIs of the clients
+	// allowed to call the todo-app, separated by commas
+	allowedClientsEnv = "ALLOWED_CLIENTS"
 )
 
 func main() {
-	// register the handler for /todo path
-	http.HandleFunc("/todo", handleTodo)
+	// reload the certificate once it is renewed, without a restart
+	watcher, err := certwatcher.New(certDir, certwatcher.Options{
+		OnReload: func(err error) {
+			if err != nil {
+				slog.Error("failed to reload certificate", "error", err)
+				return
+			}
+			slog.Info("reloaded certificate")
+		},
+	})
+	if err != nil {
+		slog.Error("failed to load certificate", "error", err)
+		os.Exit(1)
+	}
+
+	go func() {
+		if err := watcher.Start(context.Background()); err != nil {
+			slog.Error("failed to watch certificate", "error", err)
+		}
+	}()
+
+	// clients must present a certificate issued by the same CA
+	server := &http.Server{
+		Addr:              port,
+		Handler:           newHandler(strings.Split(os.Getenv(allowedClientsEnv), ",")),
+		TLSConfig:         watcher.ServerConfig(true),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
 	slog.Info("starting HTTP server", "port", port)
-	err := http.ListenAndServeTLS(port, certFile, keyFile, nil)
+	err = server.ListenAndServeTLS("", "")
 	if err != nil {
 		slog.Error("server failure", "error", err)
 	}
 }
 
+// newHandler returns the handler of the todo-app, serving the clients
+// whose certificate holds one of the allowed DNS names or URIs
+func newHandler(allowed []string) http.Handler {
+	mux := http.NewServeMux()
+
+	// register the handler for /todo path
+	mux.HandleFunc("/todo", handleTodo)
+
+	return authorize(allowed, mux)
+}
+
+// authorize rejects clients whose certificate holds none of the allowed
+// DNS names or URIs. The certificate was verified during the handshake.
+func authorize(allowed []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+
+		crt := r.TLS.PeerCertificates[0]
+		names := append([]string{}, crt.DNSNames...)
+		for _, uri := range crt.URIs {
+			names = append(names, uri.String())
+		}
+
+		for _, name := range names {
+			if name != "" && slices.Contains(allowed, name) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		slog.Info("rejected client", "names", names)
+		http.Error(w, "client not allowed", http.StatusForbidden)
+	})
+}
+
 // handleTodo handles the /todo URL path
 func handleTodo(w http.ResponseWriter, _ *http.Request) {
 	slog.Info("request received at path /todo")
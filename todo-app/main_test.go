@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"certificate-manager/pkg/certwatcher"
+)
+
+const (
+	serverName = "todo-app.todo.svc.cluster.local"
+	clientURI  = "spiffe://cluster.local/ns/todo/sa/todo-client"
+)
+
+// authority issues certificates the way the certificate manager does,
+// into directories laid out like the mounted Secret.
+type authority struct {
+	key *ecdsa.PrivateKey
+	crt *x509.Certificate
+	pem []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate CA key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "certificate-manager", Organization: []string{"k8c"}},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create CA certificate: %v", err)
+	}
+
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unable to parse CA certificate: %v", err)
+	}
+
+	return &authority{key: key, crt: crt, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for the DNS name, URIs and usages to a new
+// directory, and returns a watcher of it.
+func (a *authority) issue(t *testing.T, dnsName string, uris []string, usages ...x509.ExtKeyUsage) *certwatcher.Watcher {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("unable to generate serial number: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsName, Organization: []string{"k8c"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{dnsName},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatalf("unable to parse URI: %v", err)
+		}
+		tmpl.URIs = append(tmpl.URIs, u)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.crt, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		certwatcher.CertFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		certwatcher.KeyFile:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		certwatcher.CAFile:   a.pem,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+	}
+
+	w, err := certwatcher.New(dir, certwatcher.Options{})
+	if err != nil {
+		t.Fatalf("unable to create watcher: %v", err)
+	}
+
+	return w
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newAuthority(t)
+	server := ca.issue(t, serverName, nil, x509.ExtKeyUsageServerAuth)
+
+	// another CA, whose clients trust the todo-app all the same
+	other := newAuthority(t)
+	other.pem = ca.pem
+
+	ts := httptest.NewUnstartedServer(newHandler([]string{clientURI}))
+	ts.Listener = tls.NewListener(ts.Listener, server.ServerConfig(true))
+	ts.Start()
+	defer ts.Close()
+
+	// get calls /todo as the client, and returns the status code
+	get := func(client *certwatcher.Watcher) (int, error) {
+		var config *tls.Config
+		if client != nil {
+			config = client.ClientConfig(serverName)
+		} else {
+			// verifies the server the same way, but presents no certificate
+			config = server.ClientConfig(serverName)
+			config.GetClientCertificate = nil
+		}
+
+		httpClient := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: config,
+				DialTLSContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					dialer := &tls.Dialer{Config: config}
+					return dialer.DialContext(ctx, network, ts.Listener.Addr().String())
+				},
+			},
+		}
+
+		resp, err := httpClient.Get("https://" + serverName + "/todo")
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+
+		return resp.StatusCode, nil
+	}
+
+	tests := []struct {
+		name   string
+		client *certwatcher.Watcher
+		status int
+	}{
+		{
+			name:   "allowed client",
+			client: ca.issue(t, "todo-client.todo.svc.cluster.local", []string{clientURI}, x509.ExtKeyUsageClientAuth),
+			status: http.StatusOK,
+		},
+		{
+			name:   "client not allowed",
+			client: ca.issue(t, "other.todo.svc.cluster.local", []string{"spiffe://cluster.local/ns/todo/sa/other"}, x509.ExtKeyUsageClientAuth),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "allowed URI without client usage",
+			client: ca.issue(t, "todo-client.todo.svc.cluster.local", []string{clientURI}, x509.ExtKeyUsageServerAuth),
+		},
+		{
+			name:   "allowed URI of another CA",
+			client: other.issue(t, "todo-client.todo.svc.cluster.local", []string{clientURI}, x509.ExtKeyUsageClientAuth),
+		},
+		{
+			name: "client without certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := get(tt.client)
+
+			// clients failing the handshake are denied before any request
+			if tt.status == 0 {
+				if err == nil {
+					t.Fatalf("expected the client to be rejected, got status %d", status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unable to call the todo-app: %v", err)
+			}
+			if status != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+// TestAuthorize covers which names get through, for the peer certificates
+// TestMutualTLS cannot issue.
+func TestAuthorize(t *testing.T) {
+	allowed := []string{clientURI, "todo-client.todo.svc.cluster.local"}
+
+	// peer returns the connection state of a client presenting a
+	// certificate for the DNS names and URIs
+	peer := func(dnsNames []string, uris ...string) *tls.ConnectionState {
+		crt := &x509.Certificate{DNSNames: dnsNames}
+		for _, uri := range uris {
+			u, err := url.Parse(uri)
+			if err != nil {
+				t.Fatalf("unable to parse URI: %v", err)
+			}
+			crt.URIs = append(crt.URIs, u)
+		}
+
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{crt}}
+	}
+
+	tests := []struct {
+		name   string
+		tls    *tls.ConnectionState
+		status int
+	}{
+		{
+			name:   "allowed URI",
+			tls:    peer(nil, clientURI),
+			status: http.StatusOK,
+		},
+		{
+			name:   "allowed DNS name",
+			tls:    peer([]string{"todo-client.todo.svc.cluster.local"}),
+			status: http.StatusOK,
+		},
+		{
+			name:   "one of several names allowed",
+			tls:    peer([]string{"other.todo.svc.cluster.local"}, "spiffe://cluster.local/ns/todo/sa/other", clientURI),
+			status: http.StatusOK,
+		},
+		{
+			name:   "no name allowed",
+			tls:    peer([]string{"other.todo.svc.cluster.local"}, "spiffe://cluster.local/ns/todo/sa/other"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "allowed name as a prefix",
+			tls:    peer(nil, clientURI+"-admin"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "empty names",
+			tls:    peer([]string{""}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no names",
+			tls:    peer(nil),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no client certificate",
+			tls:    &tls.ConnectionState{},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "no TLS",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/todo", nil)
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+
+			authorize(allowed, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+
+	// an empty name is not allowed by an empty entry either
+	req := httptest.NewRequest(http.MethodGet, "/todo", nil)
+	req.TLS = peer([]string{""})
+	rec := httptest.NewRecorder()
+	authorize([]string{""}, http.NotFoundHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected an empty name to be rejected, got status %d", rec.Code)
+	}
+}